@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gesquive/cli"
+	"github.com/gesquive/krypt/crypto"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// calibrateCmd represents the calibrate command
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate [flags]",
+	Short: "Tune the key derivation function to this machine",
+	Long: `Benchmark the key derivation function on this machine and pick the parameters
+that take about the target time to unlock a file without going over the memory budget.
+The chosen parameters are saved to the config file and used for newly sealed files.`,
+	Args:   cobra.NoArgs,
+	PreRun: runCalibratePreRun,
+	Run:    runCalibrate,
+}
+
+func init() {
+	RootCmd.AddCommand(calibrateCmd)
+
+	calibrateCmd.PersistentFlags().StringP("kdf", "k", "ARGON2ID",
+		"The key derivation function to calibrate. Use the list command for a full list.")
+	calibrateCmd.PersistentFlags().DurationP("target", "t", 500*time.Millisecond,
+		"The time it should take to unlock a file")
+	calibrateCmd.PersistentFlags().IntP("memory", "m", 256,
+		"The most memory in MiB the key derivation function may use")
+
+	viper.BindEnv("kdf")
+}
+
+func runCalibratePreRun(cmd *cobra.Command, args []string) {
+	viper.BindPFlag("kdf", cmd.PersistentFlags().Lookup("kdf"))
+}
+
+func runCalibrate(cmd *cobra.Command, args []string) {
+	kdfName := viper.GetString("kdf")
+	kdf, err := crypto.GetKDFByName(kdfName)
+	if err != nil {
+		cli.Fatal("unknown key derivation function specified")
+	}
+	target, _ := cmd.PersistentFlags().GetDuration("target")
+	memory, _ := cmd.PersistentFlags().GetInt("memory")
+	if memory <= 0 {
+		cli.Fatal("memory budget must be positive")
+	}
+
+	cli.Info("Calibrating %s for %s using up to %dMiB", kdf.GetName(), target, memory)
+	kdf, err = crypto.CalibrateKDF(kdf.GetType(), target, uint64(memory)*1024*1024)
+	if err != nil {
+		cli.Fatal("Could not calibrate %s: %v", kdfName, err)
+	}
+
+	start := time.Now()
+	if _, err := kdf.DeriveKey([]byte("krypt"), []byte("calibrate"), 32); err != nil {
+		cli.Fatal("Could not calibrate %s: %v", kdfName, err)
+	}
+	elapsed := time.Since(start)
+
+	params := kdfConfigParams(kdf)
+	for _, param := range params {
+		cli.Info("%20s: %v", param.key, param.value)
+	}
+	cli.Info("%20s: %s", "unlock time", elapsed.Round(time.Millisecond))
+
+	configPath := cliGetConfigPath()
+	if err := saveKDFConfig(configPath, kdf, params); err != nil {
+		cli.Error("Could not save config '%s': %v", configPath, err)
+		cli.Info("Add these settings to the config file to use them:")
+		cli.Info("kdf: %s", kdf.GetName())
+		section := ""
+		for _, param := range params {
+			keys := strings.SplitN(param.key, ".", 2)
+			if keys[0] != section {
+				section = keys[0]
+				cli.Info("%s:", section)
+			}
+			cli.Info("  %s: %v", keys[1], param.value)
+		}
+		os.Exit(exitError)
+	}
+	cli.Info("Saved parameters to %s", configPath)
+}
+
+// kdfConfigParam is a kdf parameter as it is stored in the config file
+type kdfConfigParam struct {
+	key   string
+	value interface{}
+}
+
+// kdfConfigParams returns the config keys and values for the kdf parameters
+func kdfConfigParams(kdf crypto.KDF) []kdfConfigParam {
+	switch k := kdf.(type) {
+	case *crypto.Argon2idKDF:
+		return []kdfConfigParam{
+			{"argon2id.time", k.Time},
+			{"argon2id.memory", k.Memory},
+			{"argon2id.threads", k.Threads},
+		}
+	case *crypto.ScryptKDF:
+		return []kdfConfigParam{
+			{"scrypt.log-n", k.LogN},
+			{"scrypt.r", k.R},
+			{"scrypt.p", k.P},
+		}
+	case *crypto.PBKDF2KDF:
+		return []kdfConfigParam{
+			{"pbkdf2.iterations", k.Iterations},
+		}
+	}
+	return nil
+}
+
+// cliGetConfigPath gets the config file to save to, which is the config file
+// 	that was loaded, or the user config file if none was found
+func cliGetConfigPath() string {
+	if configPath := viper.ConfigFileUsed(); configPath != "" {
+		return configPath
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		cli.Fatal("Could not find a config file to save to, please specify one")
+	}
+	return filepath.Join(home, ".config", "krypt", "config.yml")
+}
+
+// saveKDFConfig saves the kdf and its parameters into the config file. Only
+// 	those keys are written, the rest of the file, comments and all, is kept
+// 	as is. Config files that are not YAML, or that cannot be edited safely,
+// 	return an error and are left alone.
+func saveKDFConfig(configPath string, kdf crypto.KDF, params []kdfConfigParam) error {
+	if ext := filepath.Ext(configPath); ext != ".yml" && ext != ".yaml" {
+		return errors.Errorf("only YAML config files can be updated")
+	}
+	content, err := ioutil.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	lines := []string{}
+	if len(content) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	}
+	if lines, err = setYAMLValue(lines, "", "kdf", kdf.GetName()); err != nil {
+		return err
+	}
+	for _, param := range params {
+		keys := strings.SplitN(param.key, ".", 2)
+		if lines, err = setYAMLValue(lines, keys[0], keys[1], fmt.Sprint(param.value)); err != nil {
+			return err
+		}
+	}
+	content = []byte(strings.Join(lines, "\n") + "\n")
+
+	// make sure the edited file says what it should before replacing it
+	config := viper.New()
+	config.SetConfigType("yaml")
+	if err := config.ReadConfig(bytes.NewReader(content)); err != nil {
+		return errors.Wrap(err, "could not update the config file safely")
+	}
+	if config.GetString("kdf") != kdf.GetName() {
+		return errors.New("could not update the config file safely")
+	}
+	for _, param := range params {
+		if config.GetString(param.key) != fmt.Sprint(param.value) {
+			return errors.New("could not update the config file safely")
+		}
+	}
+
+	mode := os.FileMode(0600)
+	if stat, err := os.Stat(configPath); err == nil {
+		mode = stat.Mode()
+	}
+	return ioutil.WriteFile(configPath, content, mode)
+}
+
+// yamlKeyLine matches a "key: value # comment" line of a YAML mapping
+var yamlKeyLine = regexp.MustCompile(`^(\s*)([^\s#:]+):(\s*)([^#]*?)(\s+#.*)?$`)
+
+// setYAMLValue sets key to value in the lines of a block style YAML file,
+// 	in the section mapping when there is one. A line that holds the key
+// 	already keeps its indent and comment, else the key is added to the end of
+// 	the section, or of the file. A section that is not a block mapping is an
+// 	error.
+func setYAMLValue(lines []string, section string, key string, value string) ([]string, error) {
+	start, end, indent := 0, len(lines), ""
+	if len(section) > 0 {
+		start = -1
+		for i, line := range lines {
+			match := yamlKeyLine.FindStringSubmatch(line)
+			if match != nil && len(match[1]) == 0 && match[2] == section {
+				if len(match[4]) > 0 {
+					return nil, errors.Errorf("%s is not a block mapping", section)
+				}
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return append(lines, section+":", "  "+key+": "+value), nil
+		}
+		end = start
+		for i := start; i < len(lines); i++ {
+			trimmed := strings.TrimSpace(lines[i])
+			if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			if !strings.HasPrefix(lines[i], " ") && !strings.HasPrefix(lines[i], "\t") {
+				break
+			}
+			end = i + 1
+			// the keys of the section are indented like its first key, deeper
+			// lines belong to nested values
+			if len(indent) == 0 {
+				indent = lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " \t"))]
+			}
+		}
+		if len(indent) == 0 {
+			indent = "  "
+		}
+	}
+
+	for i := start; i < end; i++ {
+		match := yamlKeyLine.FindStringSubmatch(lines[i])
+		if match != nil && match[1] == indent && match[2] == key {
+			lines[i] = match[1] + key + ":" + match[3] + value + match[5]
+			if len(match[3]) == 0 {
+				lines[i] = match[1] + key + ": " + value + match[5]
+			}
+			return lines, nil
+		}
+	}
+	added := append([]string{}, lines[:end]...)
+	added = append(added, indent+key+": "+value)
+	return append(added, lines[end:]...), nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gesquive/krypt/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveKDFConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kdf := crypto.NewArgon2idKDF(3, 65536, 4)
+	added := "kdf: ARGON2ID\nargon2id:\n  time: 3\n  memory: 65536\n  threads: 4\n"
+	tests := []struct {
+		name   string
+		config string
+		saved  string
+	}{
+		{"empty", "", added},
+		{"missing section", "cipher: AES256\n", "cipher: AES256\n" + added},
+		{"commented section", "#argon2id:\n#  time: 1\n", "#argon2id:\n#  time: 1\n" + added},
+		{"existing section",
+			"# krypt settings\ncipher: AES256 # default\nkdf: SCRYPT\nargon2id:\n  # tuned elsewhere\n  time: 1 # slow\n  memory: 1024\nscrypt:\n  log-n: 15\n",
+			"# krypt settings\ncipher: AES256 # default\nkdf: ARGON2ID\nargon2id:\n  # tuned elsewhere\n  time: 3 # slow\n  memory: 65536\n  threads: 4\nscrypt:\n  log-n: 15\n"},
+		{"other indent",
+			"argon2id:\n    time: 1\n    extra:\n        time: 9\n",
+			"argon2id:\n    time: 3\n    extra:\n        time: 9\n    memory: 65536\n    threads: 4\nkdf: ARGON2ID\n"},
+		{"section comment", "argon2id: # tuned\n time: 1\n", "argon2id: # tuned\n time: 3\n memory: 65536\n threads: 4\nkdf: ARGON2ID\n"},
+	}
+	for _, test := range tests {
+		configPath := filepath.Join(dir, "config.yml")
+		if err := ioutil.WriteFile(configPath, []byte(test.config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := saveKDFConfig(configPath, kdf, kdfConfigParams(kdf))
+		assert.NoError(t, err, "%s: save failed", test.name)
+		saved, _ := ioutil.ReadFile(configPath)
+		assert.Equal(t, test.saved, string(saved), "%s: config mismatch", test.name)
+	}
+
+	// a missing file is created
+	configPath := filepath.Join(dir, "new", "config.yaml")
+	assert.NoError(t, saveKDFConfig(configPath, kdf, kdfConfigParams(kdf)), "new file: save failed")
+	saved, _ := ioutil.ReadFile(configPath)
+	assert.Equal(t, added, string(saved), "new file: config mismatch")
+}
+
+func TestSaveKDFConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kdf := crypto.NewArgon2idKDF(3, 65536, 4)
+	tests := []struct {
+		name   string
+		file   string
+		config string
+	}{
+		{"flow section", "config.yml", "argon2id: {time: 1, memory: 1024}\n"},
+		{"flow file", "config.yml", "{cipher: AES256}\n"},
+		{"tab indent", "config.yml", "argon2id:\n\ttime: 1\n"},
+		{"json", "config.json", "{\"cipher\": \"AES256\"}\n"},
+	}
+	for _, test := range tests {
+		configPath := filepath.Join(dir, test.file)
+		if err := ioutil.WriteFile(configPath, []byte(test.config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := saveKDFConfig(configPath, kdf, kdfConfigParams(kdf))
+		assert.Error(t, err, "%s: save should fail", test.name)
+		saved, _ := ioutil.ReadFile(configPath)
+		assert.Equal(t, test.config, string(saved), "%s: config should be left alone", test.name)
+	}
+}
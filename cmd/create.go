@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/gesquive/cli"
+	"github.com/gesquive/krypt/crypto"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -25,30 +26,61 @@ func init() {
 	createCmd.PersistentFlags().StringP("editor", "e", "", "The editor to use")
 	createCmd.PersistentFlags().StringP("password-file", "p", "",
 		"The password file")
+	createCmd.PersistentFlags().StringSlice("keyfile", []string{},
+		"Mix the contents of this file, of any size, into the password. Can be given multiple times.")
 	createCmd.PersistentFlags().StringP("cipher", "i", "AES256",
 		"The cipher to encrypt with. Use the list command for a full list.")
+	createCmd.PersistentFlags().StringP("kdf", "k", "ARGON2ID",
+		"The key derivation function to use. Use the list command for a full list.")
+	createCmd.PersistentFlags().Bool("allow-legacy", false,
+		"Allow encrypting with a legacy cipher, which are decrypt-only by default")
+	createCmd.PersistentFlags().StringSliceP("recipient", "r", []string{},
+		"Seal to this public key, or the public keys in this file, instead of a password. Can be given multiple times.")
+	createCmd.PersistentFlags().StringSlice("ssh-recipient", []string{},
+		"Seal to this SSH public key, or the keys in this authorized_keys file. Can be given multiple times.")
+	createCmd.PersistentFlags().Bool("ssh-agent", false,
+		"Seal to the keys held in the running ssh-agent, which can then open the file without a password")
+	createCmd.PersistentFlags().String("encoding", "binary",
+		"How to write the sealed file: binary, base64, base64url, base32, hex or armor")
 	createCmd.PersistentFlags().BoolP("encode-text", "t", false,
-		"encode the output in base64")
+		"encode the output as ASCII armored text")
+	createCmd.PersistentFlags().MarkDeprecated("encode-text", "use --encoding armor instead")
 
 	viper.BindEnv("editor")
 	viper.BindEnv("cipher")
+	viper.BindEnv("kdf")
+	viper.BindEnv("allow-legacy")
 	viper.BindEnv("password")
 	viper.BindEnv("password-file")
+	viper.BindEnv("keyfile")
+	viper.BindEnv("recipient")
+	viper.BindEnv("ssh-recipient")
+	viper.BindEnv("ssh-agent")
+	viper.BindEnv("encoding")
 	viper.BindEnv("encode-text")
 }
 
 func runCreatePreRun(cmd *cobra.Command, args []string) {
 	viper.BindPFlag("editor", cmd.PersistentFlags().Lookup("editor"))
 	viper.BindPFlag("cipher", cmd.PersistentFlags().Lookup("cipher"))
+	viper.BindPFlag("kdf", cmd.PersistentFlags().Lookup("kdf"))
+	viper.BindPFlag("allow-legacy", cmd.PersistentFlags().Lookup("allow-legacy"))
 	viper.BindPFlag("password-file", cmd.PersistentFlags().Lookup("password-file"))
+	viper.BindPFlag("keyfile", cmd.PersistentFlags().Lookup("keyfile"))
+	viper.BindPFlag("recipient", cmd.PersistentFlags().Lookup("recipient"))
+	viper.BindPFlag("ssh-recipient", cmd.PersistentFlags().Lookup("ssh-recipient"))
+	viper.BindPFlag("ssh-agent", cmd.PersistentFlags().Lookup("ssh-agent"))
+	viper.BindPFlag("encoding", cmd.PersistentFlags().Lookup("encoding"))
 	viper.BindPFlag("encode-text", cmd.PersistentFlags().Lookup("encode-text"))
 }
 
 func runCreate(cmd *cobra.Command, args []string) {
 	cipherType := cliGetCipherType()
-	password := cliGetPassword()
+	sealOptions, closeAgent := cliGetSealOptions()
+	defer closeAgent()
+	password := cliGetSealPassword(sealOptions)
 	editor := cliGetEditor()
-	encodeText := viper.GetBool("encode-text")
+	encoding := cliGetEncoding(crypto.EncodingBinary)
 
 	file := args[0]
 
@@ -59,7 +91,7 @@ func runCreate(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	if err := writeCrypt(cipherType, password, file, newPlainText, encodeText); err != nil {
+	if err := writeCrypt(cipherType, password, sealOptions, file, newPlainText, encoding); err != nil {
 		cli.Error("Could not encrypt data for file '%s'", file)
 		cli.Debug("%v", err)
 		return
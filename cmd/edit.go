@@ -2,9 +2,9 @@ package cmd
 
 import (
 	"bytes"
+	"os"
 
 	"github.com/gesquive/cli"
-	"github.com/gesquive/krypt/crypto"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -29,36 +29,70 @@ func init() {
 	editCmd.PersistentFlags().StringP("editor", "e", "", "The editor to use")
 	editCmd.PersistentFlags().StringP("password-file", "p", "",
 		"The password file")
+	editCmd.PersistentFlags().StringSlice("keyfile", []string{},
+		"Mix the contents of this file, of any size, into the password. Can be given multiple times.")
 	editCmd.PersistentFlags().StringP("cipher", "i", "AES256",
 		"The cipher to encrypt with. Use the list command for a full list.")
+	editCmd.PersistentFlags().StringP("kdf", "k", "ARGON2ID",
+		"The key derivation function to use. Use the list command for a full list.")
+	editCmd.PersistentFlags().Bool("allow-legacy", false,
+		"Allow encrypting with a legacy cipher, which are decrypt-only by default")
+	editCmd.PersistentFlags().StringSliceP("recipient", "r", []string{},
+		"Seal to this public key, or the public keys in this file, instead of a password. Can be given multiple times.")
+	editCmd.PersistentFlags().StringSlice("ssh-recipient", []string{},
+		"Seal to this SSH public key, or the keys in this authorized_keys file. Can be given multiple times.")
+	editCmd.PersistentFlags().Bool("ssh-agent", false,
+		"Seal to the keys held in the running ssh-agent, which can then open the file without a password")
+	editCmd.PersistentFlags().StringSlice("identity", []string{},
+		"Open with the private keys in this file instead of a password. Can be given multiple times.")
+	editCmd.PersistentFlags().StringSlice("ssh-identity", []string{},
+		"Open with this SSH private key file instead of a password. Can be given multiple times.")
+	editCmd.PersistentFlags().StringSlice("share", []string{},
+		"Open with this share, or share file, from the split command. Give as many as the split needs.")
 
 	viper.BindEnv("editor")
 	viper.BindEnv("cipher")
+	viper.BindEnv("kdf")
+	viper.BindEnv("allow-legacy")
 	viper.BindEnv("password")
 	viper.BindEnv("password-file")
+	viper.BindEnv("keyfile")
+	viper.BindEnv("recipient")
+	viper.BindEnv("ssh-recipient")
+	viper.BindEnv("ssh-agent")
+	viper.BindEnv("identity")
+	viper.BindEnv("ssh-identity")
+	viper.BindEnv("share")
 }
 
 func runEditPreRun(cmd *cobra.Command, args []string) {
 	viper.BindPFlag("editor", cmd.PersistentFlags().Lookup("editor"))
 	viper.BindPFlag("cipher", cmd.PersistentFlags().Lookup("cipher"))
+	viper.BindPFlag("kdf", cmd.PersistentFlags().Lookup("kdf"))
+	viper.BindPFlag("allow-legacy", cmd.PersistentFlags().Lookup("allow-legacy"))
 	viper.BindPFlag("password-file", cmd.PersistentFlags().Lookup("password-file"))
+	viper.BindPFlag("keyfile", cmd.PersistentFlags().Lookup("keyfile"))
+	viper.BindPFlag("recipient", cmd.PersistentFlags().Lookup("recipient"))
+	viper.BindPFlag("ssh-recipient", cmd.PersistentFlags().Lookup("ssh-recipient"))
+	viper.BindPFlag("ssh-agent", cmd.PersistentFlags().Lookup("ssh-agent"))
+	viper.BindPFlag("identity", cmd.PersistentFlags().Lookup("identity"))
+	viper.BindPFlag("ssh-identity", cmd.PersistentFlags().Lookup("ssh-identity"))
+	viper.BindPFlag("share", cmd.PersistentFlags().Lookup("share"))
 }
 
 func runEdit(cmd *cobra.Command, args []string) {
 	cipherType := cliGetCipherType()
-	password := cliGetPassword()
+	sealOptions, closeSealAgent := cliGetSealOptions()
+	defer closeSealAgent()
+	openOptions, closeOpenAgent := cliGetOpenOptions()
+	defer closeOpenAgent()
+	password := cliGetOpenPassword(openOptions)
 	editor := cliGetEditor()
 
 	file := args[0]
-	origPlainText, encoded, err := readCrypt(password, file)
+	origPlainText, envelope, encoding, err := readCrypt(password, openOptions, file)
 	if err != nil {
-		if _, ok := err.(*crypto.DataIsNotEncryptedError); ok {
-			cli.Error("File is not encrypted, cannot decrypt")
-			return
-		}
-		cli.Error("Could not decrypt file '%s'", file)
-		cli.Debug("%v", err)
-		return
+		os.Exit(cliOpenError(file, err))
 	}
 
 	newPlainText, err := cliRunFileEdit(editor, origPlainText)
@@ -73,7 +107,14 @@ func runEdit(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	if err := writeCrypt(cipherType, password, file, newPlainText, encoded); err != nil {
+	// files sealed to recipients stay sealed to them
+	if envelope != nil || len(sealOptions.Recipients) > 0 {
+		sealOptions.Envelope = envelope
+		password = ""
+	} else if len(password) == 0 {
+		password = promptedPassword
+	}
+	if err := writeCrypt(cipherType, password, sealOptions, file, newPlainText, encoding); err != nil {
 		cli.Error("Could not encrypt data for file '%s'", file)
 		cli.Debug("%v", err)
 		return
@@ -1,13 +1,15 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
-	"encoding/base64"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"syscall"
 
@@ -16,6 +18,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
@@ -54,132 +58,398 @@ func readFile(filePath string) ([]byte, error) {
 	return contents, nil
 }
 
-// writeFile opens a file and write contents in
-func writeFile(filePath string, contents []byte) error {
-	fileObj, err := os.Create(filePath)
+// replaceFile writes new content for filePath to a temporary file next to it
+// 	using write, and only moves it over the original once write succeeds.
+func replaceFile(filePath string, write func(w io.Writer) error) error {
+	fileMode := os.FileMode(0644)
+	if info, err := os.Stat(filePath); err == nil {
+		fileMode = info.Mode()
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(filePath), "."+filepath.Base(filePath))
 	if err != nil {
 		return errors.Wrapf(err, "could not open file to write")
 	}
-	defer fileObj.Close()
+	defer func() {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+	}()
 
-	if _, err = io.Copy(fileObj, bytes.NewReader(contents)); err != nil {
+	if err := write(tmpFile); err != nil {
+		return err
+	}
+	if err := tmpFile.Chmod(fileMode); err != nil {
+		return errors.Wrapf(err, "could not write to file")
+	}
+	if err := tmpFile.Close(); err != nil {
 		return errors.Wrapf(err, "could not write to file")
 	}
+	if err := os.Rename(tmpFile.Name(), filePath); err != nil {
+		return errors.Wrapf(err, "could not write to file")
+	}
+	return nil
+}
+
+// openCrypt returns a reader that decrypts the sealed contents of cipherText,
+// 	the envelope when the contents were sealed to recipients, and how the
+// 	contents were encoded
+func openCrypt(password string, opts crypto.Options, cipherText io.Reader) (io.Reader, *crypto.Envelope, crypto.Encoding, error) {
+	cipherText, encoding, err := decodeSealed(bufio.NewReader(cipherText))
+	if err != nil {
+		return nil, nil, crypto.EncodingNone, err
+	}
+	if encoding.IsText() {
+		cli.Debug("%s encoded text found, decoding", encoding)
+	}
+
+	plainText, envelope, err := crypto.NewDecryptReaderWithEnvelope(cipherText, []byte(password), opts)
+	if err != nil {
+		return nil, nil, encoding, errors.Wrapf(err, "could not decrypt data")
+	}
+	return plainText, envelope, encoding, nil
+}
+
+// exit codes of the commands that open files, so scripts can tell why a file
+// 	could not be opened
+const (
+	exitError              = 1
+	exitWrongPassword      = 2
+	exitKeyfileRequired    = 3
+	exitNotKrypt           = 4
+	exitTruncated          = 5
+	exitTampered           = 6
+	exitUnsupportedVersion = 7
+)
+
+// cliOpenError reports why filePath could not be opened, and returns the exit
+// 	code for it
+func cliOpenError(filePath string, err error) int {
+	switch {
+	case errors.Is(err, crypto.ErrNotKrypt):
+		cli.Error("%s is not encrypted, cannot decrypt", filePath)
+		return exitNotKrypt
+	case errors.Is(err, crypto.ErrKeyfileRequired):
+		cli.Error("%s needs a keyfile along with the password, use --keyfile", filePath)
+		return exitKeyfileRequired
+	case errors.Is(err, crypto.ErrWrongPassword):
+		cli.Error("Could not decrypt %s, wrong password or key", filePath)
+		return exitWrongPassword
+	case errors.Is(err, crypto.ErrTruncated):
+		cli.Error("%s is truncated, cannot decrypt", filePath)
+		return exitTruncated
+	case errors.Is(err, crypto.ErrTampered):
+		cli.Error("%s has been altered, or is corrupt or cut short, will not decrypt", filePath)
+		return exitTampered
+	case errors.Is(err, crypto.ErrUnsupportedVersion):
+		cli.Error("%s was sealed by a newer version of krypt, cannot decrypt", filePath)
+		return exitUnsupportedVersion
+	}
+	cli.Error("Could not decrypt %s", filePath)
+	cli.Debug("%v", err)
+	return exitError
+}
+
+// sealCrypt encrypts everything read from plainText and writes it to cipherText
+// 	in the encoding
+func sealCrypt(cipherType crypto.CipherType, password string, opts crypto.Options, cipherText io.Writer, plainText io.Reader, encoding crypto.Encoding) error {
+	if encoding.IsText() {
+		cli.Debug("encoding output as %s", encoding)
+	}
+	encoder, err := crypto.NewEncoder(cipherText, encoding)
+	if err != nil {
+		return errors.Wrapf(err, "could not encode data")
+	}
+	cipherText = encoder
+
+	writer, err := crypto.NewEncryptWriterWithOptions(cipherText, cipherType, []byte(password), opts)
+	if err != nil {
+		if errors.Is(err, crypto.ErrAlreadyKrypt) {
+			return err
+		}
+		return errors.Wrapf(err, "could not encrypt data")
+	}
+	if _, err := io.Copy(writer, plainText); err != nil {
+		return errors.Wrapf(err, "could not encrypt data")
+	}
+	if err := writer.Close(); err != nil {
+		return errors.Wrapf(err, "could not encrypt data")
+	}
+
+	if err := encoder.Close(); err != nil {
+		return errors.Wrapf(err, "could not encode data")
+	}
 	return nil
 }
 
 // readCrypt opens a file, reads it and decrypts the contents
-func readCrypt(password string, filePath string) ([]byte, bool, error) {
+func readCrypt(password string, opts crypto.Options, filePath string) ([]byte, *crypto.Envelope, crypto.Encoding, error) {
 	var empty []byte
-	cipherText, err := readFile(filePath)
+	fileObj, err := os.Open(filePath)
 	if err != nil {
-		return empty, false, err
+		return empty, nil, crypto.EncodingNone, errors.Wrapf(err, "could not open file to read")
 	}
+	defer fileObj.Close()
 
-	var encoded = false
-	if decodedText, err := base64Decode(cipherText); err == nil {
-		cli.Debug("encoded text found, decoding")
-		cipherText = decodedText
-		encoded = true
+	reader, envelope, encoding, err := openCrypt(password, opts, fileObj)
+	if err != nil {
+		return empty, nil, encoding, err
 	}
 
-	plainText, err := crypto.Decrypt([]byte(password), cipherText)
+	plainText, err := ioutil.ReadAll(reader)
 	if err != nil {
-		if derr, ok := err.(*crypto.DataIsNotEncryptedError); ok {
-			return empty, encoded, derr
-		}
-		return empty, encoded, errors.Wrapf(err, "could not decrypt data")
+		return empty, nil, encoding, errors.Wrapf(err, "could not decrypt data")
 	}
-	return plainText, encoded, nil
+	return plainText, envelope, encoding, nil
 }
 
 // writeCrypt encrypts the plain text and writes to filePath
-func writeCrypt(cipherType crypto.CipherType, password string, filePath string, plainText []byte, encodeOutput bool) error {
-	cipherText, err := crypto.Encrypt(cipherType, []byte(password), plainText)
+func writeCrypt(cipherType crypto.CipherType, password string, opts crypto.Options, filePath string, plainText []byte, encoding crypto.Encoding) error {
+	return replaceFile(filePath, func(w io.Writer) error {
+		return sealCrypt(cipherType, password, opts, w, bytes.NewReader(plainText), encoding)
+	})
+}
+
+// encryptFile streams the contents of a file through the cipher and writes back the cipher text
+func encryptFile(cipherType crypto.CipherType, password string, opts crypto.Options, filePath string, encoding crypto.Encoding) error {
+	fileObj, err := os.Open(filePath)
 	if err != nil {
-		if derr, ok := err.(*crypto.DataIsEncryptedError); ok {
-			return derr
-		}
-		return errors.Wrapf(err, "could not encrypt data")
+		return errors.Wrapf(err, "could not open file to read")
 	}
+	defer fileObj.Close()
 
-	if encodeOutput {
-		cli.Debug("encoding output")
-		cipherText = base64Encode(cipherText)
+	reader := bufio.NewReader(fileObj)
+	if start, _ := reader.Peek(crypto.InspectSize); crypto.IsSealed(start) {
+		return crypto.NewDataIsEcryptedError()
 	}
+	return replaceFile(filePath, func(w io.Writer) error {
+		return sealCrypt(cipherType, password, opts, w, reader, encoding)
+	})
+}
 
-	if err := writeFile(filePath, cipherText); err != nil {
+// decryptFile streams the contents of a file through the cipher and writes back the plain text
+func decryptFile(password string, opts crypto.Options, filePath string) (crypto.Encoding, error) {
+	fileObj, err := os.Open(filePath)
+	if err != nil {
+		return crypto.EncodingNone, errors.Wrapf(err, "could not open file to read")
+	}
+	defer fileObj.Close()
+
+	var encoding = crypto.EncodingNone
+	err = replaceFile(filePath, func(w io.Writer) error {
+		var reader io.Reader
+		var err error
+		reader, _, encoding, err = openCrypt(password, opts, fileObj)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, reader); err != nil {
+			return errors.Wrapf(err, "could not decrypt data")
+		}
+		return nil
+	})
+	return encoding, err
+}
+
+// resealFile replaces the key slot of the old password with one for the new
+// 	password, keeping the other slots. When the cipher is unchanged only the
+// 	header is rewritten, else the contents are streamed through the old and the
+// 	new cipher. The file is written in the encoding, or in its original
+// 	encoding when that is EncodingNone.
+func resealFile(cipherType crypto.CipherType, oldPassword string, oldOpts crypto.Options, password string, opts crypto.Options, filePath string, encoding crypto.Encoding) error {
+	var fileCipher crypto.CipherType
+	var stanzas []*crypto.Stanza
+	err := readSealed(filePath, func(r io.Reader) error {
+		var err error
+		fileCipher, err = crypto.ReadCipherType(r)
 		return err
+	})
+	if err == nil {
+		err = readSealed(filePath, func(r io.Reader) error {
+			var err error
+			stanzas, err = crypto.ReadStanzas(r)
+			return err
+		})
 	}
-	return nil
+	if err != nil {
+		return errors.Wrapf(err, "could not read key slots")
+	}
+
+	if len(stanzas) > 0 {
+		envelope, err := readEnvelope(oldPassword, oldOpts, filePath)
+		if err != nil {
+			return err
+		}
+		newSlots := append(append([]crypto.Recipient{}, opts.Recipients...), crypto.NewPasswordRecipientWithKeyfile([]byte(password), opts.Keyfile, opts.KDF))
+		for _, recipient := range newSlots {
+			if err := envelope.AddRecipient(recipient); err != nil {
+				return errors.Wrapf(err, "could not add key slot")
+			}
+		}
+		if err := envelope.RemoveStanza(envelope.Opened()); err != nil {
+			return errors.Wrapf(err, "could not remove key slot")
+		}
+
+		if fileCipher == cipherType {
+			cli.Debug("cipher is unchanged, rewrapping the key slots")
+			return rewrapFile(envelope, filePath, encoding)
+		}
+		// the new slots are in the envelope already
+		opts.Envelope = envelope
+		opts.Recipients = nil
+		password = ""
+	}
+
+	fileObj, err := os.Open(filePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not open file to read")
+	}
+	defer fileObj.Close()
+
+	return replaceFile(filePath, func(w io.Writer) error {
+		plainText, _, fileEncoding, err := openCrypt(oldPassword, oldOpts, fileObj)
+		if err != nil {
+			return err
+		}
+		if encoding == crypto.EncodingNone {
+			encoding = fileEncoding
+		}
+		return sealCrypt(cipherType, password, opts, w, plainText, encoding)
+	})
 }
 
-// encryptFile opens a file, encrypts the contents, and writes back the cipher text
-func encryptFile(cipherType crypto.CipherType, password string, filePath string, encodeOutput bool) error {
-	plainText, err := readFile(filePath)
+// opensslBase64Magic starts the base64 of salted openssl enc data
+var opensslBase64Magic = []byte("U2FsdGVkX1")
+
+// importFile opens a file encrypted by openssl enc with the legacy cipher and
+// 	seals its contents in place. Files written by openssl enc -a are decoded
+// 	from base64 first.
+func importFile(opensslCipher crypto.CipherType, oldPassword string, opensslOpts crypto.OpenSSLOptions, cipherType crypto.CipherType, password string, opts crypto.Options, filePath string, encoding crypto.Encoding) error {
+	data, err := readFile(filePath)
 	if err != nil {
 		return err
 	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), opensslBase64Magic) {
+		decoder, err := crypto.NewDecoder(bytes.NewReader(data), crypto.EncodingBase64)
+		if err != nil {
+			return errors.Wrapf(err, "could not decode data")
+		}
+		if data, err = ioutil.ReadAll(decoder); err != nil {
+			return errors.Wrapf(err, "could not decode data")
+		}
+	}
 
-	err = writeCrypt(cipherType, password, filePath, plainText, encodeOutput)
+	plainText, err := crypto.DecryptOpenSSL(opensslCipher, []byte(oldPassword), data, opensslOpts)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "could not decrypt data")
 	}
-	return nil
+	return writeCrypt(cipherType, password, opts, filePath, plainText, encoding)
 }
 
-// decryptFile opens a file, decrypts the contents, and writes back the plain text
-func decryptFile(password string, filePath string) (bool, error) {
-	plainText, encoded, err := readCrypt(password, filePath)
+// readEnvelope opens the key slots of a file with the password or identities,
+// 	files sealed before key slots have none and return a nil envelope
+func readEnvelope(password string, opts crypto.Options, filePath string) (*crypto.Envelope, error) {
+	fileObj, err := os.Open(filePath)
 	if err != nil {
-		return encoded, err
+		return nil, errors.Wrapf(err, "could not open file to read")
 	}
+	defer fileObj.Close()
 
-	if err := writeFile(filePath, plainText); err != nil {
-		return encoded, err
-	}
-	return encoded, nil
+	_, envelope, _, err := openCrypt(password, opts, fileObj)
+	return envelope, err
 }
 
-func base64Encode(data []byte) []byte {
-	encodedData := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
-	base64.StdEncoding.Encode(encodedData, data)
-	return encodedData
+// rewrapFile writes back a file with the key slots of the envelope, only the
+// 	header is rewritten, the payload is copied as is. The file is written in
+// 	the encoding, or in its original encoding when that is EncodingNone.
+func rewrapFile(envelope *crypto.Envelope, filePath string, encoding crypto.Encoding) error {
+	fileObj, err := os.Open(filePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not open file to read")
+	}
+	defer fileObj.Close()
+
+	return replaceFile(filePath, func(w io.Writer) error {
+		cipherText, fileEncoding, err := decodeSealed(bufio.NewReader(fileObj))
+		if err != nil {
+			return err
+		}
+		if encoding == crypto.EncodingNone {
+			encoding = fileEncoding
+		}
+		encoder, err := crypto.NewEncoder(w, encoding)
+		if err != nil {
+			return errors.Wrapf(err, "could not encode data")
+		}
+
+		if err := crypto.RewrapEnvelope(encoder, cipherText, envelope); err != nil {
+			return errors.Wrapf(err, "could not rewrap data key")
+		}
+		if err := encoder.Close(); err != nil {
+			return errors.Wrapf(err, "could not encode data")
+		}
+		return nil
+	})
 }
 
-func base64Decode(data []byte) ([]byte, error) {
-	var decodedData []byte
-	decodedData = make([]byte, base64.StdEncoding.DecodedLen(len(data)))
-	decodedLen, err := base64.StdEncoding.Decode(decodedData, data)
+// readSealed opens a file and passes its sealed contents to read, decoded when
+// 	the file is text encoded
+func readSealed(filePath string, read func(r io.Reader) error) error {
+	fileObj, err := os.Open(filePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not open file to read")
+	}
+	defer fileObj.Close()
+
+	cipherText, _, err := decodeSealed(bufio.NewReader(fileObj))
 	if err != nil {
-		return decodedData, err
+		return err
 	}
-	return decodedData[:decodedLen], nil
+	return read(cipherText)
+}
+
+// decodeSealed peeks at the start of the stream to tell how the sealed data
+// 	in it is encoded, and returns a reader of the decoded data. Data that is
+// 	not sealed is a DataIsNotEncryptedError.
+func decodeSealed(reader *bufio.Reader) (io.Reader, crypto.Encoding, error) {
+	// as much as is buffered, to tell base64url from base64
+	start, _ := reader.Peek(reader.Size())
+	encoding := crypto.Inspect(start)
+	if encoding == crypto.EncodingNone {
+		return nil, encoding, crypto.NewDataIsNotEncryptedError()
+	}
+	decoder, err := crypto.NewDecoder(reader, encoding)
+	return decoder, encoding, err
 }
 
 func cliGetPassword() string {
+	return cliGetNamedPassword("password", "Enter password: ")
+}
+
+// cliGetNamedPassword gets a password from the name config entry, the file in
+// 	the name-file entry or else by asking with prompt
+func cliGetNamedPassword(name string, prompt string) string {
 	// if a password is provided, use it
-	envPassword := strings.TrimSpace(viper.GetString("password"))
+	envPassword := strings.TrimSpace(viper.GetString(name))
 	if len(envPassword) > 0 {
-		cli.Debug("password src: cli")
-		return viper.GetString("password")
+		cli.Debug("%s src: cli", name)
+		return viper.GetString(name)
 	}
 	// if a password-file is provided, use the password in it
-	passwordFilePath := viper.GetString("password-file")
+	passwordFilePath := viper.GetString(name + "-file")
 	if len(passwordFilePath) > 0 {
 		if _, err := os.Stat(passwordFilePath); os.IsNotExist(err) {
-			cli.Error("password-file: does not exist (\"%s\")", passwordFilePath)
+			cli.Error("%s-file: does not exist (\"%s\")", name, passwordFilePath)
 		} else {
 			filePassword, err := ioutil.ReadFile(passwordFilePath)
 			if err != nil {
-				cli.Error("password-file: could not open (\"%s\")", passwordFilePath)
+				cli.Error("%s-file: could not open (\"%s\")", name, passwordFilePath)
 			} else {
 				filePassword = bytes.TrimSpace(filePassword)
 				if len(filePassword) > 0 {
-					cli.Debug("password-file: \"%s\"", passwordFilePath)
+					cli.Debug("%s-file: \"%s\"", name, passwordFilePath)
 					return string(filePassword)
 				}
-				cli.Error("password-file: file is empty (\"%s\")", passwordFilePath)
+				cli.Error("%s-file: file is empty (\"%s\")", name, passwordFilePath)
 			}
 		}
 
@@ -187,7 +457,7 @@ func cliGetPassword() string {
 	// no password has been provided, kindly pester the user for a valid password
 	var userPassword []byte
 	for len(userPassword) == 0 {
-		fmt.Print("Enter password: ")
+		fmt.Print(prompt)
 		userPassword, _ = terminal.ReadPassword(int(syscall.Stdin))
 		fmt.Print("\n")
 		userPassword = bytes.TrimSpace(userPassword)
@@ -198,17 +468,349 @@ func cliGetPassword() string {
 	return string(userPassword)
 }
 
+// cliGetSealPassword gets the password to seal with, none is needed when
+// 	sealing to recipients
+func cliGetSealPassword(opts crypto.Options) string {
+	if len(opts.Recipients) > 0 {
+		return ""
+	}
+	return cliGetPassword()
+}
+
+// cliGetOpenPassword gets the password to open with. With identities that
+// 	may open the files instead, the password is only asked for once a file
+// 	sealed with one is opened, see cliPromptPassword.
+func cliGetOpenPassword(opts crypto.Options) string {
+	if len(opts.Identities) > 0 {
+		return ""
+	}
+	return cliGetPassword()
+}
+
+// promptedPassword holds the password once cliPromptPassword asked for it
+var promptedPassword string
+
+// cliPromptPassword asks for the password the first time a file sealed with
+// 	one is opened, and reuses it for the files after
+func cliPromptPassword() ([]byte, error) {
+	if len(promptedPassword) == 0 {
+		promptedPassword = cliGetPassword()
+	}
+	return []byte(promptedPassword), nil
+}
+
 func cliGetCipherType() crypto.CipherType {
 	cipherName := viper.GetString("cipher")
 	cipherType, err := crypto.GetCipherTypeByName(cipherName)
 	if err != nil || cipherType == crypto.Unknown {
 		cli.Fatal("unknown encryption cipher specified")
 	}
+	if crypto.IsLegacyCipher(cipherType) && !viper.GetBool("allow-legacy") {
+		cli.Fatal("%s is a legacy cipher and can only decrypt, use --allow-legacy to encrypt with it", cipherName)
+	}
 
 	cli.Debug("cipher: '%s'", cipherName)
 	return cipherType
 }
 
+// cliGetEncoding returns the encoding to write sealed files in, the deprecated
+// 	encode-text flag of seal and create picks armor. EncodingNone as the
+// 	default keeps the encoding of each file.
+func cliGetEncoding(defaultEncoding crypto.Encoding) crypto.Encoding {
+	name := viper.GetString("encoding")
+	if defaultEncoding != crypto.EncodingNone && viper.GetBool("encode-text") && name == defaultEncoding.String() {
+		name = crypto.EncodingArmor.String()
+	}
+	if len(name) == 0 {
+		return defaultEncoding
+	}
+	encoding, err := crypto.ParseEncoding(name)
+	if err != nil {
+		cli.Fatal("unknown encoding '%s', use one of binary, base64, base64url, base32, hex or armor", name)
+	}
+	cli.Debug("encoding: '%s'", encoding)
+	return encoding
+}
+
+func cliGetKDF() crypto.KDF {
+	kdfName := viper.GetString("kdf")
+	kdf, err := crypto.GetKDFByName(kdfName)
+	if err != nil {
+		cli.Fatal("unknown key derivation function specified")
+	}
+
+	// use the calibrated parameters from the config, if there are any
+	switch k := kdf.(type) {
+	case *crypto.Argon2idKDF:
+		k.Time = viperGetUint32("argon2id.time", k.Time)
+		k.Memory = viperGetUint32("argon2id.memory", k.Memory)
+		k.Threads = uint8(viperGetUint32("argon2id.threads", uint32(k.Threads)))
+	case *crypto.ScryptKDF:
+		k.LogN = uint8(viperGetUint32("scrypt.log-n", uint32(k.LogN)))
+		k.R = viperGetUint32("scrypt.r", k.R)
+		k.P = viperGetUint32("scrypt.p", k.P)
+	case *crypto.PBKDF2KDF:
+		k.Iterations = viperGetUint32("pbkdf2.iterations", k.Iterations)
+	}
+
+	cli.Debug("kdf: '%s' %v", kdfName, kdfConfigParams(kdf))
+	return kdf
+}
+
+// viperGetUint32 gets a config value, or the default when it is not set
+func viperGetUint32(key string, defaultValue uint32) uint32 {
+	if !viper.IsSet(key) {
+		return defaultValue
+	}
+	return viper.GetUint32(key)
+}
+
+// cliGetSealOptions gets the options used to seal files, and a func that
+// 	closes the ssh-agent connection they use once the files are sealed
+func cliGetSealOptions() (crypto.Options, func()) {
+	recipients, closeAgent := cliGetRecipients()
+	return crypto.Options{
+		KDF:         cliGetKDF(),
+		AllowLegacy: viper.GetBool("allow-legacy"),
+		Recipients:  recipients,
+		Keyfile:     cliGetKeyfile("keyfile"),
+	}, closeAgent
+}
+
+// cliGetOpenOptions gets the options used to open files, and a func that
+// 	closes the ssh-agent connection they use once the files are opened
+func cliGetOpenOptions() (crypto.Options, func()) {
+	identities, closeAgent := cliGetIdentities()
+	return crypto.Options{
+		Identities:     identities,
+		PasswordPrompt: cliPromptPassword,
+		Keyfile:        cliGetKeyfile("keyfile"),
+	}, closeAgent
+}
+
+// cliGetKeyfile hashes the keyfiles in the name config entry, or returns nil
+// 	when none are given
+func cliGetKeyfile(name string) []byte {
+	keyfilePaths := viper.GetStringSlice(name)
+	if len(keyfilePaths) == 0 {
+		return nil
+	}
+	keyfiles := []io.Reader{}
+	for _, keyfilePath := range keyfilePaths {
+		fileObj, err := os.Open(keyfilePath)
+		if err != nil {
+			cli.Fatal("%s: could not open (\"%s\")", name, keyfilePath)
+		}
+		defer fileObj.Close()
+		cli.Debug("%s: \"%s\"", name, keyfilePath)
+		keyfiles = append(keyfiles, fileObj)
+	}
+	keyfile, err := crypto.HashKeyfiles(keyfiles...)
+	if err != nil {
+		cli.Fatal("%s: could not read: %v", name, err)
+	}
+	return keyfile
+}
+
+// cliGetRecipients gets the recipients to seal to. Each recipient is either a
+// 	public key, a file with one public key per line or the name of a key in
+// 	the keyring. The returned func closes the ssh-agent connection of
+// 	--ssh-agent recipients.
+func cliGetRecipients() ([]crypto.Recipient, func()) {
+	recipients := []crypto.Recipient{}
+	for _, value := range viper.GetStringSlice("recipient") {
+		if recipient, err := crypto.ParseRecipient(value); err == nil {
+			recipients = append(recipients, recipient)
+			continue
+		}
+
+		fileObj, err := os.Open(value)
+		if err != nil {
+			recipient, kerr := cliGetKeyringRecipient(value)
+			if kerr != nil {
+				cli.Fatal("recipient: not a public key, readable file or keyring key (\"%s\")", value)
+			}
+			cli.Debug("recipient: keyring key \"%s\"", value)
+			recipients = append(recipients, recipient)
+			continue
+		}
+		fileRecipients, err := crypto.ParseRecipients(fileObj)
+		fileObj.Close()
+		if err != nil {
+			cli.Fatal("recipient: could not read \"%s\": %v", value, err)
+		}
+		recipients = append(recipients, fileRecipients...)
+	}
+	recipients = append(recipients, cliGetSSHRecipients()...)
+	closeAgent := func() {}
+	if viper.GetBool("ssh-agent") {
+		var agentKeys []*crypto.SSHAgentKey
+		agentKeys, closeAgent = cliGetSSHAgentKeys()
+		if len(agentKeys) == 0 {
+			cli.Fatal("ssh-agent: no ssh-ed25519 or ssh-rsa keys found in the agent")
+		}
+		for _, agentKey := range agentKeys {
+			recipients = append(recipients, agentKey)
+		}
+	}
+
+	cli.Debug("recipients: %d", len(recipients))
+	return recipients, closeAgent
+}
+
+// cliGetIdentities gets the identities to open files with. Each identity is
+// 	either a file of private keys, a private key file sealed with a password
+// 	or the name of a key in the keyring. The returned func closes the
+// 	ssh-agent connection of the agent identities.
+func cliGetIdentities() ([]crypto.Identity, func()) {
+	identities := []crypto.Identity{}
+	for _, value := range viper.GetStringSlice("identity") {
+		if _, err := os.Stat(value); os.IsNotExist(err) {
+			identity, err := cliGetKeyringIdentity(value)
+			if err != nil {
+				cli.Fatal("identity: could not open keyring key \"%s\": %v", value, err)
+			}
+			cli.Debug("identity: keyring key \"%s\"", value)
+			identities = append(identities, identity)
+			continue
+		}
+
+		content, err := readFile(value)
+		if err != nil {
+			cli.Fatal("identity: could not open (\"%s\")", value)
+		}
+		fileIdentities, err := crypto.ParseIdentities(bytes.NewReader(content))
+		if err != nil {
+			// not plain private keys, try a sealed private key file
+			if _, serr := readSealedKey(content); serr != nil {
+				cli.Fatal("identity: could not read \"%s\": %v", value, err)
+			}
+			identity, err := openPrivateKey(content, cliGetKeyPassword(filepath.Base(value)))
+			if err != nil {
+				cli.Fatal("identity: could not open \"%s\": %v", value, err)
+			}
+			fileIdentities = []crypto.Identity{identity}
+		}
+		cli.Debug("identity: \"%s\"", value)
+		identities = append(identities, fileIdentities...)
+	}
+	identities = append(identities, cliGetSSHIdentities()...)
+	if shareIdentity := cliGetShareIdentity(); shareIdentity != nil {
+		identities = append(identities, shareIdentity)
+	}
+	agentKeys, closeAgent := cliGetSSHAgentKeys()
+	for _, agentKey := range agentKeys {
+		identities = append(identities, agentKey)
+	}
+	return identities, closeAgent
+}
+
+// cliGetSSHAgentKeys gets the keys held in the ssh-agent at SSH_AUTH_SOCK,
+// 	there are none when no agent is running. The keys use the agent
+// 	connection until the returned func closes it.
+func cliGetSSHAgentKeys() ([]*crypto.SSHAgentKey, func()) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if len(socket) == 0 {
+		return nil, func() {}
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		cli.Debug("ssh-agent: could not connect (\"%s\"): %v", socket, err)
+		return nil, func() {}
+	}
+	agentKeys, err := crypto.SSHAgentKeys(agent.NewClient(conn))
+	if err != nil {
+		cli.Debug("ssh-agent: %v", err)
+		conn.Close()
+		return nil, func() {}
+	}
+	cli.Debug("ssh-agent: %d keys", len(agentKeys))
+	if len(agentKeys) == 0 {
+		conn.Close()
+		return nil, func() {}
+	}
+	return agentKeys, func() { conn.Close() }
+}
+
+// cliGetSSHRecipients gets the SSH public keys to seal to. Each recipient is
+// 	either an SSH public key or an authorized_keys file.
+func cliGetSSHRecipients() []crypto.Recipient {
+	recipients := []crypto.Recipient{}
+	for _, value := range viper.GetStringSlice("ssh-recipient") {
+		if recipient, err := crypto.ParseSSHRecipient(value); err == nil {
+			recipients = append(recipients, recipient)
+			continue
+		}
+
+		fileObj, err := os.Open(value)
+		if err != nil {
+			cli.Fatal("ssh-recipient: not an ssh public key or readable file (\"%s\")", value)
+		}
+		fileRecipients, err := crypto.ParseSSHRecipients(fileObj)
+		fileObj.Close()
+		if err != nil {
+			cli.Fatal("ssh-recipient: could not read \"%s\": %v", value, err)
+		}
+		recipients = append(recipients, fileRecipients...)
+	}
+	return recipients
+}
+
+// cliGetSSHIdentities gets the SSH private keys to open files with, asking
+// 	for the passphrase of encrypted keys
+func cliGetSSHIdentities() []crypto.Identity {
+	identities := []crypto.Identity{}
+	for _, identityPath := range viper.GetStringSlice("ssh-identity") {
+		pemBytes, err := readFile(identityPath)
+		if err != nil {
+			cli.Fatal("ssh-identity: could not open (\"%s\")", identityPath)
+		}
+		identity, err := crypto.ParseSSHIdentity(pemBytes)
+		if _, ok := err.(*ssh.PassphraseMissingError); ok {
+			passphrase := cliGetKeyPassword(filepath.Base(identityPath))
+			identity, err = crypto.ParseSSHIdentityWithPassphrase(pemBytes, []byte(passphrase))
+		}
+		if err != nil {
+			cli.Fatal("ssh-identity: could not read \"%s\": %v", identityPath, err)
+		}
+		cli.Debug("ssh-identity: \"%s\"", identityPath)
+		identities = append(identities, identity)
+	}
+	return identities
+}
+
+// cliGetShareIdentity combines the shares given to open with, each is either
+// 	a share or a share file from the split command
+func cliGetShareIdentity() crypto.Identity {
+	values := viper.GetStringSlice("share")
+	if len(values) == 0 {
+		return nil
+	}
+	shares := []string{}
+	for _, value := range values {
+		if _, err := os.Stat(value); os.IsNotExist(err) {
+			shares = append(shares, value)
+			continue
+		}
+		content, err := readFile(value)
+		if err != nil {
+			cli.Fatal("share: could not open (\"%s\")", value)
+		}
+		share, err := readShare(content)
+		if err != nil {
+			cli.Fatal("share: could not read \"%s\": %v", value, err)
+		}
+		cli.Debug("share: \"%s\"", value)
+		shares = append(shares, share)
+	}
+
+	identity, err := crypto.CombineShares(shares)
+	if err != nil {
+		cli.Fatal("share: could not combine the shares: %v", err)
+	}
+	return identity
+}
+
 // cliRunFileEdit creates a temporary file and opens it with the given editor.
 // 	If the editor successfully returns, the contents of the temporary file are returned.
 func cliRunFileEdit(editor string, content []byte) ([]byte, error) {
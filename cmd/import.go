@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/gesquive/cli"
+	"github.com/gesquive/krypt/crypto"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import [flags] FILE [FILE...]",
+	Short: "Seal file(s) encrypted by openssl enc with a legacy cipher",
+	Long: `Open files that openssl enc encrypted with Blowfish (bf-cbc) or Triple-DES (des-ede3-cbc)
+and seal their contents again, in place. This command can operate on multiple files at once.
+openssl enc does not store how it derived the key, so give the same -md and -pbkdf2 -iter it was run with.
+Files written with openssl enc -a are read as base64. The openssl data is not authenticated,
+a wrong password or option is only caught most of the time.`,
+	ValidArgs: []string{"FILE"},
+	Args:      VerifyMinimumNFileArgs(1),
+	PreRun:    runImportPreRun,
+	Run:       runImport,
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+
+	importCmd.PersistentFlags().String("openssl-cipher", "",
+		"The cipher openssl enc used: BLOWFISH for bf-cbc or TDES for des-ede3-cbc")
+	importCmd.PersistentFlags().String("openssl-md", "sha256",
+		"The -md openssl enc used: md5, sha1 or sha256. OpenSSL before 1.1.0 used md5.")
+	importCmd.PersistentFlags().Int("openssl-iter", 0,
+		"The -iter openssl enc -pbkdf2 used, which is 10000 when not given. Leave at 0 without -pbkdf2.")
+	importCmd.PersistentFlags().StringP("old-password-file", "o", "",
+		"The password file openssl enc used.")
+	importCmd.PersistentFlags().StringP("password-file", "p", "",
+		"The password file to seal with.")
+	importCmd.PersistentFlags().StringSlice("keyfile", []string{},
+		"Mix the contents of this file, of any size, into the password. Can be given multiple times.")
+	importCmd.PersistentFlags().StringP("cipher", "i", "AES256",
+		"The cipher to seal with. Use the list command for a full list.")
+	importCmd.PersistentFlags().StringP("kdf", "k", "ARGON2ID",
+		"The key derivation function to use. Use the list command for a full list.")
+	importCmd.PersistentFlags().String("encoding", "binary",
+		"How to write the sealed file: binary, base64, base64url, base32, hex or armor")
+
+	viper.BindEnv("openssl-cipher")
+	viper.BindEnv("openssl-md")
+	viper.BindEnv("openssl-iter")
+	viper.BindEnv("old-password")
+	viper.BindEnv("old-password-file")
+	viper.BindEnv("password")
+	viper.BindEnv("password-file")
+	viper.BindEnv("keyfile")
+	viper.BindEnv("cipher")
+	viper.BindEnv("kdf")
+	viper.BindEnv("encoding")
+}
+
+func runImportPreRun(cmd *cobra.Command, args []string) {
+	viper.BindPFlag("openssl-cipher", cmd.PersistentFlags().Lookup("openssl-cipher"))
+	viper.BindPFlag("openssl-md", cmd.PersistentFlags().Lookup("openssl-md"))
+	viper.BindPFlag("openssl-iter", cmd.PersistentFlags().Lookup("openssl-iter"))
+	viper.BindPFlag("old-password-file", cmd.PersistentFlags().Lookup("old-password-file"))
+	viper.BindPFlag("password-file", cmd.PersistentFlags().Lookup("password-file"))
+	viper.BindPFlag("keyfile", cmd.PersistentFlags().Lookup("keyfile"))
+	viper.BindPFlag("cipher", cmd.PersistentFlags().Lookup("cipher"))
+	viper.BindPFlag("kdf", cmd.PersistentFlags().Lookup("kdf"))
+	viper.BindPFlag("encoding", cmd.PersistentFlags().Lookup("encoding"))
+}
+
+func runImport(cmd *cobra.Command, args []string) {
+	opensslCipher := cliGetOpenSSLCipherType()
+	opensslOptions := crypto.OpenSSLOptions{
+		Digest:     viper.GetString("openssl-md"),
+		Iterations: viper.GetInt("openssl-iter"),
+	}
+	cipherType := cliGetCipherType()
+	sealOptions, closeAgent := cliGetSealOptions()
+	defer closeAgent()
+	oldPassword := cliGetOldPassword()
+	password := cliGetSealPassword(sealOptions)
+	encoding := cliGetEncoding(crypto.EncodingBinary)
+
+	exitCode := 0
+	for _, file := range args {
+		cli.Debug("import %s", file)
+		err := importFile(opensslCipher, oldPassword, opensslOptions, cipherType, password, sealOptions, file, encoding)
+		if err != nil {
+			var code int
+			if errors.Is(err, crypto.ErrNotKrypt) {
+				cli.Error("%s was not encrypted by openssl enc with a salt, skipping", file)
+				code = exitNotKrypt
+			} else {
+				code = cliOpenError(file, err)
+			}
+			if exitCode == 0 {
+				exitCode = code
+			}
+		}
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// cliGetOpenSSLCipherType gets the legacy cipher openssl enc used
+func cliGetOpenSSLCipherType() crypto.CipherType {
+	cipherName := viper.GetString("openssl-cipher")
+	if len(cipherName) == 0 {
+		cli.Fatal("the cipher openssl enc used is needed, use --openssl-cipher")
+	}
+	cipherType, err := crypto.GetCipherTypeByName(cipherName)
+	if err != nil || !crypto.IsOpenSSLCipher(cipherType) {
+		cli.Fatal("openssl enc files can only be imported from BLOWFISH or TDES")
+	}
+
+	cli.Debug("openssl-cipher: '%s'", cipherName)
+	return cipherType
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/gesquive/cli"
+	"github.com/gesquive/krypt/crypto"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// infoCmd represents the info command
+var infoCmd = &cobra.Command{
+	Use:     "info [flags] FILE [FILE...]",
+	Aliases: []string{"i"},
+	Short:   "Show how encrypted file(s) were sealed",
+	Long: `Show how encrypted files were sealed, without a password: the encoding, format
+version, cipher, key slots or key derivation function, chunk size and the
+sizes of the header and payload. With --json the same is listed in JSON for
+scripts.`,
+	ValidArgs: []string{"FILE"},
+	Args:      VerifyMinimumNFileArgs(1),
+	PreRun:    runInfoPreRun,
+	Run:       runInfo,
+}
+
+func init() {
+	RootCmd.AddCommand(infoCmd)
+
+	infoCmd.Flags().Bool("json", false,
+		"List the file info as JSON")
+}
+
+func runInfoPreRun(cmd *cobra.Command, args []string) {
+	viper.BindPFlag("json", cmd.Flags().Lookup("json"))
+}
+
+// fileInfo is how a file was sealed, as shown by the info command
+type fileInfo struct {
+	File          string                 `json:"file"`
+	Encoding      string                 `json:"encoding"`
+	Version       uint8                  `json:"version"`
+	Cipher        string                 `json:"cipher"`
+	Flags         uint16                 `json:"flags"`
+	KDF           string                 `json:"kdf,omitempty"`
+	KDFParams     map[string]interface{} `json:"kdf_params,omitempty"`
+	KeySlots      []string               `json:"key_slots,omitempty"`
+	ChunkSize     int                    `json:"chunk_size,omitempty"`
+	FileSize      int64                  `json:"file_size"`
+	HeaderSize    int64                  `json:"header_size"`
+	PayloadSize   int64                  `json:"payload_size"`
+	PlainTextSize *int64                 `json:"plain_text_size,omitempty"`
+
+	kdfParams []kdfConfigParam
+}
+
+func runInfo(cmd *cobra.Command, args []string) {
+	jsonOutput := viper.GetBool("json")
+
+	exitCode := 0
+	infos := []*fileInfo{}
+	for _, file := range args {
+		info, err := readFileInfo(file)
+		if err != nil {
+			var code int
+			if errors.Is(err, crypto.ErrNotKrypt) {
+				cli.Error("%s is not encrypted", file)
+				code = exitNotKrypt
+			} else {
+				code = cliOpenError(file, err)
+			}
+			if exitCode == 0 {
+				exitCode = code
+			}
+			continue
+		}
+		if jsonOutput {
+			infos = append(infos, info)
+		} else {
+			printFileInfo(info)
+		}
+	}
+
+	if jsonOutput {
+		content, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			cli.Fatal("Could not encode the info: %v", err)
+		}
+		fmt.Println(string(content))
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// readFileInfo reads how a file was sealed from its header, and measures its
+// 	payload
+func readFileInfo(filePath string) (*fileInfo, error) {
+	fileObj, err := os.Open(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open file to read")
+	}
+	defer fileObj.Close()
+	stat, err := fileObj.Stat()
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open file to read")
+	}
+
+	cipherText, encoding, err := decodeSealed(bufio.NewReader(fileObj))
+	if err != nil {
+		return nil, err
+	}
+	header, err := crypto.ParseHeader(cipherText)
+	if err != nil {
+		return nil, err
+	}
+	payloadSize, err := io.Copy(ioutil.Discard, cipherText)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read payload")
+	}
+
+	info := &fileInfo{
+		File:        filePath,
+		Encoding:    encoding.String(),
+		Version:     header.Version,
+		Cipher:      fmt.Sprintf("unknown (%d)", header.Cipher),
+		Flags:       header.Flags,
+		ChunkSize:   header.ChunkSize,
+		FileSize:    stat.Size(),
+		HeaderSize:  header.Size,
+		PayloadSize: payloadSize,
+	}
+	if cipherInfo, err := crypto.GetCipherInfo(header.Cipher); err == nil {
+		info.Cipher = cipherInfo.Name
+	}
+	if header.KDF != nil {
+		info.KDF = header.KDF.GetName()
+		info.KDFParams = map[string]interface{}{}
+		info.kdfParams = kdfConfigParams(header.KDF)
+		for _, param := range info.kdfParams {
+			info.KDFParams[param.key] = param.value
+		}
+	}
+	for _, stanza := range header.Stanzas {
+		info.KeySlots = append(info.KeySlots, slotDescription(stanza))
+	}
+	if plainTextSize, ok := header.PlainTextSize(payloadSize); ok {
+		info.PlainTextSize = &plainTextSize
+	}
+	return info, nil
+}
+
+// printFileInfo prints how a file was sealed
+func printFileInfo(info *fileInfo) {
+	cli.Info("%s", info.File)
+	cli.Info("%16s: %s", "encoding", info.Encoding)
+	cli.Info("%16s: %d", "version", info.Version)
+	cli.Info("%16s: %s", "cipher", info.Cipher)
+	if len(info.KeySlots) > 0 {
+		cli.Info("%16s: %s", "key slots", strings.Join(info.KeySlots, ", "))
+	}
+	if len(info.KDF) > 0 {
+		cli.Info("%16s: %s", "kdf", info.KDF)
+		for _, param := range info.kdfParams {
+			cli.Info("%16s: %v", param.key, param.value)
+		}
+	}
+	if info.ChunkSize > 0 {
+		cli.Info("%16s: %d bytes", "chunk size", info.ChunkSize)
+	}
+	cli.Info("%16s: %d bytes", "file size", info.FileSize)
+	cli.Info("%16s: %d bytes", "header size", info.HeaderSize)
+	cli.Info("%16s: %d bytes", "payload size", info.PayloadSize)
+	if info.PlainTextSize != nil {
+		cli.Info("%16s: %d bytes", "plain text size", *info.PlainTextSize)
+	}
+}
@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gesquive/cli"
+	"github.com/gesquive/krypt/crypto"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// The keyring holds each key as two text files named after the key. NAME.pub
+// holds the public key and NAME.key holds the private key sealed with a
+// password, base64 encoded. Both start with '#' comment lines describing the
+// key, which are ignored when the key is read.
+const (
+	publicKeyExt  = ".pub"
+	privateKeyExt = ".key"
+	keyLineWidth  = 64
+)
+
+var keyNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// fingerprinter is implemented by recipients with a fingerprint
+type fingerprinter interface {
+	Fingerprint() string
+}
+
+// keyringKey is a key stored in the keyring
+type keyringKey struct {
+	name       string
+	recipient  crypto.Recipient
+	hasPrivate bool
+}
+
+// cliGetKeyringPath gets the directory holding the keyring
+func cliGetKeyringPath() string {
+	if keyringPath := viper.GetString("keyring"); keyringPath != "" {
+		return keyringPath
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".config", "krypt", "keys")
+	}
+	return filepath.Join(home, ".config", "krypt", "keys")
+}
+
+// validKeyName reports whether the name can be used for a key in the keyring
+func validKeyName(name string) bool {
+	return keyNamePattern.MatchString(name)
+}
+
+// keyPath returns the path of a key file in the keyring
+func keyPath(keyringPath string, name string, ext string) string {
+	return filepath.Join(keyringPath, name+ext)
+}
+
+// keyExists reports whether the keyring holds a key file with the given name
+func keyExists(keyringPath string, name string, ext string) bool {
+	if !validKeyName(name) {
+		return false
+	}
+	_, err := os.Stat(keyPath(keyringPath, name, ext))
+	return err == nil
+}
+
+// listKeys returns the keys in the keyring sorted by name
+func listKeys(keyringPath string) ([]keyringKey, error) {
+	files, err := ioutil.ReadDir(keyringPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "could not read keyring")
+	}
+
+	keys := []keyringKey{}
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != publicKeyExt {
+			continue
+		}
+		name := strings.TrimSuffix(file.Name(), publicKeyExt)
+		recipient, err := readPublicKey(keyPath(keyringPath, name, publicKeyExt))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, keyringKey{
+			name:       name,
+			recipient:  recipient,
+			hasPrivate: keyExists(keyringPath, name, privateKeyExt),
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].name < keys[j].name })
+	return keys, nil
+}
+
+// keyFingerprint returns the fingerprint of a recipient, if it has one
+func keyFingerprint(recipient crypto.Recipient) string {
+	if f, ok := recipient.(fingerprinter); ok {
+		return f.Fingerprint()
+	}
+	return ""
+}
+
+// readPublicKey reads the public key from a NAME.pub file
+func readPublicKey(filePath string) (crypto.Recipient, error) {
+	fileObj, err := os.Open(filePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open key")
+	}
+	defer fileObj.Close()
+
+	recipients, err := crypto.ParseRecipients(fileObj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read key %s", filePath)
+	}
+	if len(recipients) != 1 {
+		return nil, errors.Errorf("could not read key %s: expected one public key", filePath)
+	}
+	return recipients[0], nil
+}
+
+// writePublicKey writes a NAME.pub file
+func writePublicKey(keyringPath string, name string, recipient crypto.Recipient) error {
+	buffer := new(bytes.Buffer)
+	fmt.Fprintf(buffer, "# krypt public key\n")
+	fmt.Fprintf(buffer, "# name: %s\n", name)
+	if fingerprint := keyFingerprint(recipient); fingerprint != "" {
+		fmt.Fprintf(buffer, "# fingerprint: %s\n", fingerprint)
+	}
+	fmt.Fprintf(buffer, "%s\n", recipient)
+	return writeKeyFile(keyPath(keyringPath, name, publicKeyExt), buffer.Bytes())
+}
+
+// sealPrivateKey seals the text form of a private key with a password and
+// 	returns the contents of a NAME.key file
+func sealPrivateKey(name string, identity fmt.Stringer, recipient crypto.Recipient, cipherType crypto.CipherType, password string, opts crypto.Options) ([]byte, error) {
+	sealed, err := crypto.EncryptWithOptions(cipherType, []byte(password), []byte(identity.String()), opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not seal private key")
+	}
+
+	buffer := new(bytes.Buffer)
+	fmt.Fprintf(buffer, "# krypt private key, sealed with a password\n")
+	fmt.Fprintf(buffer, "# name: %s\n", name)
+	fmt.Fprintf(buffer, "# created: %s\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(buffer, "# public key: %s\n", recipient)
+	encoded := base64.StdEncoding.EncodeToString(sealed)
+	for len(encoded) > keyLineWidth {
+		fmt.Fprintf(buffer, "%s\n", encoded[:keyLineWidth])
+		encoded = encoded[keyLineWidth:]
+	}
+	fmt.Fprintf(buffer, "%s\n", encoded)
+	return buffer.Bytes(), nil
+}
+
+// readSealedKey returns the sealed private key held in the contents of a
+// 	NAME.key file
+func readSealedKey(content []byte) ([]byte, error) {
+	encoded := new(strings.Builder)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		encoded.WriteString(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded.String())
+	if err != nil || len(sealed) == 0 {
+		return nil, errors.New("not a sealed private key")
+	}
+	return sealed, nil
+}
+
+// openPrivateKey opens the contents of a NAME.key file with a password
+func openPrivateKey(content []byte, password string) (crypto.Identity, error) {
+	sealed, err := readSealedKey(content)
+	if err != nil {
+		return nil, err
+	}
+	plainText, err := crypto.Decrypt([]byte(password), sealed)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open private key")
+	}
+	return crypto.ParseIdentity(string(plainText))
+}
+
+// writeKeyFile writes a key file that only the user can read
+func writeKeyFile(filePath string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return errors.Wrapf(err, "could not create keyring")
+	}
+
+	// replaceFile keeps the mode of an existing file
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		if err := ioutil.WriteFile(filePath, nil, 0600); err != nil {
+			return errors.Wrapf(err, "could not write key")
+		}
+	}
+	err := replaceFile(filePath, func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	})
+	if err != nil {
+		os.Remove(filePath)
+	}
+	return err
+}
+
+// identityRecipient returns the public key of a private key
+func identityRecipient(identity crypto.Identity) (crypto.Recipient, error) {
+	switch i := identity.(type) {
+	case *crypto.X25519Identity:
+		return i.Recipient(), nil
+	default:
+		return nil, errors.New("unknown identity type")
+	}
+}
+
+// cliGetKeyringRecipient reads the public key of a key in the keyring
+func cliGetKeyringRecipient(name string) (crypto.Recipient, error) {
+	keyringPath := cliGetKeyringPath()
+	if !keyExists(keyringPath, name, publicKeyExt) {
+		return nil, errors.Errorf("no key named \"%s\" in the keyring", name)
+	}
+	return readPublicKey(keyPath(keyringPath, name, publicKeyExt))
+}
+
+// cliGetKeyringIdentity opens the private key of a key in the keyring
+func cliGetKeyringIdentity(name string) (crypto.Identity, error) {
+	keyringPath := cliGetKeyringPath()
+	if !keyExists(keyringPath, name, privateKeyExt) {
+		return nil, errors.Errorf("no private key named \"%s\" in the keyring", name)
+	}
+	content, err := readFile(keyPath(keyringPath, name, privateKeyExt))
+	if err != nil {
+		return nil, err
+	}
+	return openPrivateKey(content, cliGetKeyPassword(name))
+}
+
+// cliGetKeyPassword gets the password a private key is sealed with
+func cliGetKeyPassword(name string) string {
+	envPassword := strings.TrimSpace(viper.GetString("key-password"))
+	if len(envPassword) > 0 {
+		cli.Debug("key password src: cli")
+		return envPassword
+	}
+
+	var userPassword []byte
+	for len(userPassword) == 0 {
+		fmt.Printf("Enter password for key %s: ", name)
+		var err error
+		userPassword, err = terminal.ReadPassword(int(syscall.Stdin))
+		fmt.Print("\n")
+		if err != nil {
+			cli.Fatal("could not read the password for key %s", name)
+		}
+		userPassword = bytes.TrimSpace(userPassword)
+		if len(userPassword) == 0 {
+			cli.Error("Password is not long enough")
+		}
+	}
+	return string(userPassword)
+}
@@ -0,0 +1,319 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/gesquive/cli"
+	"github.com/gesquive/krypt/crypto"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// keysCmd represents the keys command group
+var keysCmd = &cobra.Command{
+	Use:     "keys command",
+	Aliases: []string{"k", "key"},
+	Short:   "Manage the keys in the keyring",
+	Long: `Manage the public and private keys in the keyring. Keys in the keyring can be
+used by name with the --recipient and --identity flags.`,
+}
+
+var keysGenerateCmd = &cobra.Command{
+	Use:     "generate [flags] NAME",
+	Aliases: []string{"gen", "g"},
+	Short:   "Generate a new key pair",
+	Long: `Generate a new key pair and add it to the keyring. The private key is sealed
+with a password, the public key is printed so it can be shared.`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: runKeysGeneratePreRun,
+	Run:    runKeysGenerate,
+}
+
+var keysListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls", "l"},
+	Short:   "List the keys in the keyring",
+	Long:    `List the name and fingerprint of every key in the keyring, and whether its private key is held.`,
+	Args:    cobra.NoArgs,
+	Run:     runKeysList,
+}
+
+var keysImportCmd = &cobra.Command{
+	Use:     "import [flags] NAME FILE",
+	Aliases: []string{"i"},
+	Short:   "Import a key into the keyring",
+	Long: `Import a public key, a private key or a sealed private key file into the
+keyring. Private keys that are not sealed yet are sealed with a password.`,
+	Args:   cobra.ExactArgs(2),
+	PreRun: runKeysImportPreRun,
+	Run:    runKeysImport,
+}
+
+var keysExportCmd = &cobra.Command{
+	Use:     "export [flags] NAME",
+	Aliases: []string{"e"},
+	Short:   "Print a key from the keyring",
+	Long: `Print the public key of a key in the keyring, or with --private the sealed
+private key file, which can be imported elsewhere.`,
+	Args:   cobra.ExactArgs(1),
+	PreRun: runKeysExportPreRun,
+	Run:    runKeysExport,
+}
+
+var keysFingerprintCmd = &cobra.Command{
+	Use:     "fingerprint KEY",
+	Aliases: []string{"f", "fp"},
+	Short:   "Print the fingerprint of a public key",
+	Long:    `Print the fingerprint of a public key, a file of public keys or a key in the keyring.`,
+	Args:    cobra.ExactArgs(1),
+	Run:     runKeysFingerprint,
+}
+
+var keysDeleteCmd = &cobra.Command{
+	Use:     "delete [flags] NAME",
+	Aliases: []string{"del", "rm"},
+	Short:   "Delete a key from the keyring",
+	Long:    `Delete a key from the keyring. Deleting a private key needs --force.`,
+	Args:    cobra.ExactArgs(1),
+	PreRun:  runKeysDeletePreRun,
+	Run:     runKeysDelete,
+}
+
+func init() {
+	RootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(keysGenerateCmd)
+	keysCmd.AddCommand(keysListCmd)
+	keysCmd.AddCommand(keysImportCmd)
+	keysCmd.AddCommand(keysExportCmd)
+	keysCmd.AddCommand(keysFingerprintCmd)
+	keysCmd.AddCommand(keysDeleteCmd)
+
+	keysCmd.PersistentFlags().String("keyring", "",
+		"The keyring directory (default \"$HOME/.config/krypt/keys\")")
+	viper.BindPFlag("keyring", keysCmd.PersistentFlags().Lookup("keyring"))
+	viper.BindEnv("keyring")
+	viper.BindEnv("key-password", "KRYPT_KEY_PASSWORD")
+
+	for _, cmd := range []*cobra.Command{keysGenerateCmd, keysImportCmd} {
+		cmd.Flags().StringP("password-file", "p", "",
+			"The password file to seal the private key with")
+		cmd.Flags().StringP("cipher", "i", "AES256",
+			"The cipher to seal the private key with. Use the list command for a full list.")
+		cmd.Flags().StringP("kdf", "k", "ARGON2ID",
+			"The key derivation function to use. Use the list command for a full list.")
+	}
+	keysExportCmd.Flags().Bool("private", false,
+		"Print the sealed private key file instead of the public key")
+	keysDeleteCmd.Flags().BoolP("force", "f", false,
+		"Delete the private key as well")
+
+	viper.BindEnv("password")
+	viper.BindEnv("password-file")
+	viper.BindEnv("cipher")
+	viper.BindEnv("kdf")
+}
+
+// bindKeySealFlags binds the flags used to seal private keys
+func bindKeySealFlags(cmd *cobra.Command) {
+	viper.BindPFlag("password-file", cmd.Flags().Lookup("password-file"))
+	viper.BindPFlag("cipher", cmd.Flags().Lookup("cipher"))
+	viper.BindPFlag("kdf", cmd.Flags().Lookup("kdf"))
+}
+
+// cliSealPrivateKey seals a private key with the password and writes it and
+// 	its public key to the keyring
+func cliSealPrivateKey(keyringPath string, name string, identity crypto.Identity) crypto.Recipient {
+	recipient, err := identityRecipient(identity)
+	if err != nil {
+		cli.Fatal("Could not read the private key")
+	}
+	stringer, ok := identity.(fmt.Stringer)
+	if !ok {
+		cli.Fatal("Could not read the private key")
+	}
+
+	cipherType := cliGetCipherType()
+	opts := crypto.Options{KDF: cliGetKDF()}
+	password := cliGetPassword()
+	content, err := sealPrivateKey(name, stringer, recipient, cipherType, password, opts)
+	if err != nil {
+		cli.Fatal("Could not seal the private key")
+	}
+	if err := writeKeyFile(keyPath(keyringPath, name, privateKeyExt), content); err != nil {
+		cli.Fatal("Could not write the private key: %v", err)
+	}
+	return recipient
+}
+
+// cliCheckKeyName exits if the name cannot be used for a key
+func cliCheckKeyName(name string) {
+	if !validKeyName(name) {
+		cli.Fatal("Invalid key name \"%s\", use letters, digits, '.', '_' and '-'", name)
+	}
+}
+
+// cliCheckNewKeyName exits if the name cannot be used for a new key
+func cliCheckNewKeyName(keyringPath string, name string) {
+	cliCheckKeyName(name)
+	if keyExists(keyringPath, name, publicKeyExt) || keyExists(keyringPath, name, privateKeyExt) {
+		cli.Fatal("A key named \"%s\" is already in the keyring", name)
+	}
+}
+
+func runKeysGeneratePreRun(cmd *cobra.Command, args []string) {
+	bindKeySealFlags(cmd)
+}
+
+func runKeysGenerate(cmd *cobra.Command, args []string) {
+	keyringPath := cliGetKeyringPath()
+	name := args[0]
+	cliCheckNewKeyName(keyringPath, name)
+
+	identity, err := crypto.GenerateX25519Identity()
+	if err != nil {
+		cli.Fatal("Could not generate a key")
+	}
+	recipient := cliSealPrivateKey(keyringPath, name, identity)
+	if err := writePublicKey(keyringPath, name, recipient); err != nil {
+		cli.Fatal("Could not write the public key: %v", err)
+	}
+
+	cli.Debug("keyring: %s", keyringPath)
+	cli.Info("Public key: %s", recipient)
+}
+
+func runKeysList(cmd *cobra.Command, args []string) {
+	keys, err := listKeys(cliGetKeyringPath())
+	if err != nil {
+		cli.Fatal("Could not list keys: %v", err)
+	}
+
+	for _, key := range keys {
+		keyKind := "public"
+		if key.hasPrivate {
+			keyKind = "private"
+		}
+		cli.Info("%-20s  %-52s  %s", key.name, keyFingerprint(key.recipient), keyKind)
+	}
+}
+
+func runKeysImportPreRun(cmd *cobra.Command, args []string) {
+	bindKeySealFlags(cmd)
+}
+
+func runKeysImport(cmd *cobra.Command, args []string) {
+	keyringPath := cliGetKeyringPath()
+	name, filePath := args[0], args[1]
+	cliCheckNewKeyName(keyringPath, name)
+
+	content, err := readFile(filePath)
+	if err != nil {
+		cli.Fatal("Could not read %s", filePath)
+	}
+
+	var recipient crypto.Recipient
+	if recipients, err := crypto.ParseRecipients(bytes.NewReader(content)); err == nil && len(recipients) == 1 {
+		recipient = recipients[0]
+	} else if identities, err := crypto.ParseIdentities(bytes.NewReader(content)); err == nil && len(identities) == 1 {
+		recipient = cliSealPrivateKey(keyringPath, name, identities[0])
+	} else if _, err := readSealedKey(content); err == nil {
+		// check the password before taking in the sealed key as it is
+		identity, err := openPrivateKey(content, cliGetKeyPassword(name))
+		if err != nil {
+			cli.Fatal("Could not open the private key: %v", err)
+		}
+		if recipient, err = identityRecipient(identity); err != nil {
+			cli.Fatal("Could not read the private key")
+		}
+		if err := writeKeyFile(keyPath(keyringPath, name, privateKeyExt), content); err != nil {
+			cli.Fatal("Could not write the private key: %v", err)
+		}
+	} else {
+		cli.Fatal("%s does not hold exactly one key", filePath)
+	}
+
+	if err := writePublicKey(keyringPath, name, recipient); err != nil {
+		cli.Fatal("Could not write the public key: %v", err)
+	}
+	cli.Info("Imported %s", name)
+}
+
+func runKeysExportPreRun(cmd *cobra.Command, args []string) {
+	viper.BindPFlag("private", cmd.Flags().Lookup("private"))
+}
+
+func runKeysExport(cmd *cobra.Command, args []string) {
+	keyringPath := cliGetKeyringPath()
+	name := args[0]
+	cliCheckKeyName(name)
+
+	if viper.GetBool("private") {
+		if !keyExists(keyringPath, name, privateKeyExt) {
+			cli.Fatal("No private key named \"%s\" in the keyring", name)
+		}
+		content, err := readFile(keyPath(keyringPath, name, privateKeyExt))
+		if err != nil {
+			cli.Fatal("Could not read the private key: %v", err)
+		}
+		fmt.Print(string(content))
+		return
+	}
+
+	recipient, err := cliGetKeyringRecipient(name)
+	if err != nil {
+		cli.Fatal("%v", err)
+	}
+	fmt.Println(recipient)
+}
+
+func runKeysFingerprint(cmd *cobra.Command, args []string) {
+	value := args[0]
+
+	var recipients []crypto.Recipient
+	if recipient, err := crypto.ParseRecipient(value); err == nil {
+		recipients = []crypto.Recipient{recipient}
+	} else if fileObj, err := os.Open(value); err == nil {
+		recipients, err = crypto.ParseRecipients(fileObj)
+		fileObj.Close()
+		if err != nil {
+			cli.Fatal("Could not read %s: %v", value, err)
+		}
+	} else {
+		recipient, err := cliGetKeyringRecipient(value)
+		if err != nil {
+			cli.Fatal("Not a public key, readable file or keyring key (\"%s\")", value)
+		}
+		recipients = []crypto.Recipient{recipient}
+	}
+
+	for _, recipient := range recipients {
+		cli.Info("%s", keyFingerprint(recipient))
+	}
+}
+
+func runKeysDeletePreRun(cmd *cobra.Command, args []string) {
+	viper.BindPFlag("force", cmd.Flags().Lookup("force"))
+}
+
+func runKeysDelete(cmd *cobra.Command, args []string) {
+	keyringPath := cliGetKeyringPath()
+	name := args[0]
+
+	hasPublic := keyExists(keyringPath, name, publicKeyExt)
+	hasPrivate := keyExists(keyringPath, name, privateKeyExt)
+	if !hasPublic && !hasPrivate {
+		cli.Fatal("No key named \"%s\" in the keyring", name)
+	}
+	if hasPrivate && !viper.GetBool("force") {
+		cli.Fatal("%s has a private key, use --force to delete it", name)
+	}
+
+	for _, ext := range []string{privateKeyExt, publicKeyExt} {
+		if err := os.Remove(keyPath(keyringPath, name, ext)); err != nil && !os.IsNotExist(err) {
+			cli.Fatal("Could not delete %s: %v", name, err)
+		}
+	}
+	cli.Info("Deleted %s", name)
+}
@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
 	"github.com/gesquive/cli"
 	"github.com/gesquive/krypt/crypto"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // listCmd represents the list command
@@ -11,18 +18,120 @@ var listCmd = &cobra.Command{
 	Use:     "list",
 	Aliases: []string{"l"},
 	Short:   "List the available cipher methods",
-	Long:    `List the name and description of all the available cipher methods`,
-	Run:     runList,
+	Long: `List the name and description of all the available cipher methods and key
+derivation functions. With --long the key, block and nonce sizes, mode, AEAD
+status, speed and aliases of every cipher are listed too, --json lists them
+all in JSON for scripts.`,
+	PreRun: runListPreRun,
+	Run:    runList,
 }
 
 func init() {
 	RootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().BoolP("long", "l", false,
+		"List the details of every cipher")
+	listCmd.Flags().Bool("json", false,
+		"List the ciphers and key derivation functions as JSON")
+}
+
+func runListPreRun(cmd *cobra.Command, args []string) {
+	viper.BindPFlag("long", cmd.Flags().Lookup("long"))
+	viper.BindPFlag("json", cmd.Flags().Lookup("json"))
+}
+
+// kdfInfo describes a key derivation function in the JSON list
+type kdfInfo struct {
+	Type        crypto.KDFType `json:"type"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
 }
 
 func runList(cmd *cobra.Command, args []string) {
+	if viper.GetBool("json") {
+		listJSON()
+		return
+	}
+
 	cli.Info("Supported Ciphers:")
 	cipherList := crypto.GetCipherList()
+	if viper.GetBool("long") {
+		listLong(cipherList)
+	} else {
+		for _, cipher := range cipherList {
+			cli.Info("%20s  %50s", cipher.GetName(), cipher.GetDescription())
+		}
+	}
+	cli.Info("Supported Key Derivation Functions:")
+	kdfList := crypto.GetKDFList()
+	for _, kdf := range kdfList {
+		cli.Info("%20s  %50s", kdf.GetName(), kdf.GetDescription())
+	}
+}
+
+// listLong lists the details of the ciphers in a table
+func listLong(cipherList []crypto.Cipher) {
+	buffer := new(bytes.Buffer)
+	table := tabwriter.NewWriter(buffer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "  NAME\tKEY\tBLOCK\tMODE\tNONCE\tAEAD\tSPEED\tNOTES")
 	for _, cipher := range cipherList {
-		cli.Info("%10s  %40s", cipher.GetName(), cipher.GetDescription())
+		info, err := crypto.GetCipherInfo(cipher.GetType())
+		if err != nil {
+			cli.Debug("%v", err)
+			cli.Fatal("Could not describe cipher %s", cipher.GetName())
+		}
+		block := "stream"
+		if info.BlockSize > 0 {
+			block = fmt.Sprintf("%d", info.BlockSize*8)
+		}
+		aead := "no"
+		if info.AEAD {
+			aead = "yes"
+		}
+		var notes []string
+		if info.Deprecated {
+			notes = append(notes, "deprecated")
+		}
+		if info.EffectiveKeySize > 0 {
+			notes = append(notes, fmt.Sprintf("%d-bit effective key", info.EffectiveKeySize*8))
+		}
+		if len(info.Layers) > 0 {
+			notes = append(notes, "layers "+strings.Join(info.Layers, ", "))
+		}
+		if len(info.Aliases) > 0 {
+			notes = append(notes, "aliases "+strings.Join(info.Aliases, ", "))
+		}
+		fmt.Fprintf(table, "  %s\t%d\t%s\t%s\t%d\t%s\t%s\t%s\n", info.Name, info.KeySize*8, block,
+			info.Mode, info.NonceSize*8, aead, info.Speed, strings.Join(notes, "; "))
+	}
+	table.Flush()
+	for _, line := range strings.Split(strings.TrimRight(buffer.String(), "\n"), "\n") {
+		cli.Info("%s", strings.TrimRight(line, " "))
+	}
+	cli.Info("  Sizes are in bits.")
+}
+
+// listJSON lists the ciphers and key derivation functions as JSON
+func listJSON() {
+	list := struct {
+		Ciphers []crypto.CipherInfo `json:"ciphers"`
+		KDFs    []kdfInfo           `json:"kdfs"`
+	}{}
+	for _, cipher := range crypto.GetCipherList() {
+		info, err := crypto.GetCipherInfo(cipher.GetType())
+		if err != nil {
+			cli.Debug("%v", err)
+			cli.Fatal("Could not describe cipher %s", cipher.GetName())
+		}
+		list.Ciphers = append(list.Ciphers, info)
+	}
+	for _, kdf := range crypto.GetKDFList() {
+		list.KDFs = append(list.KDFs, kdfInfo{Type: kdf.GetType(), Name: kdf.GetName(), Description: kdf.GetDescription()})
+	}
+
+	content, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		cli.Fatal("Could not encode the list: %v", err)
 	}
+	fmt.Println(string(content))
 }
@@ -1,26 +1,23 @@
 package cmd
 
 import (
-	"bytes"
-	"fmt"
-	"io/ioutil"
 	"os"
-	"strings"
-	"syscall"
 
 	"github.com/gesquive/cli"
 	"github.com/gesquive/krypt/crypto"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"golang.org/x/crypto/ssh/terminal"
 )
 
 // resealCmd represents the reseal command
 var resealCmd = &cobra.Command{
-	Use:       "reseal [flags] FILE",
-	Aliases:   []string{"r", "resl"},
-	Short:     "Change the password/cipher on encrypted file(s)",
-	Long:      `Change the password/cipher on encrypted file(s). This command can operate on multiple files at once.`,
+	Use:     "reseal [flags] FILE",
+	Aliases: []string{"r", "resl"},
+	Short:   "Change the password/cipher on encrypted file(s)",
+	Long: `Change the password/cipher on encrypted file(s). This command can operate on multiple files at once.
+The key slot of the old password is replaced by one for the new password, other slots are kept.
+When the cipher is unchanged only the key slots are rewritten, without encrypting the contents again.`,
 	ValidArgs: []string{"FILE"},
 	Args:      VerifyMinimumNFileArgs(1),
 	PreRun:    runResealPreRun,
@@ -32,87 +29,76 @@ func init() {
 
 	resealCmd.PersistentFlags().StringP("cipher", "i", "AES256",
 		"The cipher to encrypt with. Use the list command for a full list.")
+	resealCmd.PersistentFlags().StringP("kdf", "k", "ARGON2ID",
+		"The key derivation function to use. Use the list command for a full list.")
+	resealCmd.PersistentFlags().Bool("allow-legacy", false,
+		"Allow encrypting with a legacy cipher, which are decrypt-only by default")
 	resealCmd.PersistentFlags().StringP("password-file", "p", "",
 		"The password file to encrypt with.")
+	resealCmd.PersistentFlags().StringSlice("keyfile", []string{},
+		"Mix the contents of this file, of any size, into the new password. Can be given multiple times.")
 	resealCmd.PersistentFlags().StringP("old-password-file", "o", "",
 		"The old password file to decrypt with.")
+	resealCmd.PersistentFlags().StringSlice("old-keyfile", []string{},
+		"The keyfile mixed into the old password. Can be given multiple times.")
+	resealCmd.PersistentFlags().String("encoding", "",
+		"Write the file as binary, base64, base64url, base32, hex or armor, instead of its current encoding")
 
 	viper.BindEnv("cipher")
+	viper.BindEnv("kdf")
+	viper.BindEnv("allow-legacy")
 	viper.BindEnv("password")
 	viper.BindEnv("password-file")
+	viper.BindEnv("keyfile")
 	viper.BindEnv("old-password")
 	viper.BindEnv("old-password-file")
+	viper.BindEnv("old-keyfile", "KRYPT_OLD_KEYFILE")
+	viper.BindEnv("encoding")
 
 }
 
 func runResealPreRun(cmd *cobra.Command, args []string) {
 	viper.BindPFlag("cipher", cmd.PersistentFlags().Lookup("cipher"))
+	viper.BindPFlag("kdf", cmd.PersistentFlags().Lookup("kdf"))
+	viper.BindPFlag("allow-legacy", cmd.PersistentFlags().Lookup("allow-legacy"))
 	viper.BindPFlag("password-file", cmd.PersistentFlags().Lookup("password-file"))
+	viper.BindPFlag("keyfile", cmd.PersistentFlags().Lookup("keyfile"))
 	viper.BindPFlag("old-password-file", cmd.PersistentFlags().Lookup("old-password-file"))
+	viper.BindPFlag("old-keyfile", cmd.PersistentFlags().Lookup("old-keyfile"))
+	viper.BindPFlag("encoding", cmd.PersistentFlags().Lookup("encoding"))
 }
 
 func runReseal(cmd *cobra.Command, args []string) {
 	cipherType := cliGetCipherType()
+	sealOptions, closeAgent := cliGetSealOptions()
+	defer closeAgent()
 	oldPassword := cliGetOldPassword()
+	oldOptions := crypto.Options{Keyfile: cliGetKeyfile("old-keyfile")}
 	password := cliGetPassword()
+	encoding := cliGetEncoding(crypto.EncodingNone)
 
+	exitCode := 0
 	for _, file := range args {
 		cli.Debug("reseal %s", file)
-		plainText, encoded, err := readCrypt(oldPassword, file)
+		err := resealFile(cipherType, oldPassword, oldOptions, password, sealOptions, file, encoding)
 		if err != nil {
-			if _, ok := err.(*crypto.DataIsNotEncryptedError); ok {
-				cli.Error("File is not encrypted, cannot decrypt")
-				continue
+			var code int
+			if errors.Is(err, crypto.ErrKeyfileRequired) {
+				cli.Error("%s needs a keyfile along with the old password, use --old-keyfile", file)
+				code = exitKeyfileRequired
+			} else {
+				code = cliOpenError(file, err)
+			}
+			if exitCode == 0 {
+				exitCode = code
 			}
-			cli.Error("Could not decrypt %s", file)
-			cli.Debug("%v", err)
-		}
-
-		err = writeCrypt(cipherType, password, file, plainText, encoded)
-		if err != nil {
-			cli.Error("Could not write to %s", file)
-			cli.Debug("%v", err)
 		}
 	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
 }
 
 func cliGetOldPassword() string {
-	// if a password is provided, use it
-	envPassword := strings.TrimSpace(viper.GetString("old-password"))
-	if len(envPassword) > 0 {
-		cli.Debug("old-password src: cli")
-		return viper.GetString("old-password")
-	}
-	// if a password-file is provided, use the password in it
-	passwordFilePath := viper.GetString("old-password-file")
-	if len(passwordFilePath) > 0 {
-		if _, err := os.Stat(passwordFilePath); !os.IsNotExist(err) {
-			cli.Error("old-password-file: does not exist (\"%s\")", passwordFilePath)
-		} else {
-			filePassword, err := ioutil.ReadFile(passwordFilePath)
-			if err != nil {
-				cli.Error("old-password-file: could not open (\"%s\")", passwordFilePath)
-			} else {
-				filePassword = bytes.TrimSpace(filePassword)
-				if len(filePassword) > 0 {
-					cli.Debug("old-password-file: (\"%s\")", passwordFilePath)
-					return string(filePassword)
-				}
-				cli.Error("old-password-file: file is empty (\"%s\")", passwordFilePath)
-			}
-		}
-
-	}
-	// no password has been provided, kindly pester the user for a valid password
-	var userPassword []byte
-	for len(userPassword) == 0 {
-		fmt.Print("Enter old password: ")
-		userPassword, _ = terminal.ReadPassword(int(syscall.Stdin))
-		fmt.Print("\n")
-		userPassword = bytes.TrimSpace(userPassword)
-		if len(userPassword) == 0 {
-			cli.Error("old-password is not long enough")
-		}
-	}
-	return string(userPassword)
+	return cliGetNamedPassword("old-password", "Enter old password: ")
 }
@@ -3,6 +3,7 @@ package cmd
 import (
 	"github.com/gesquive/cli"
 	"github.com/gesquive/krypt/crypto"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -24,33 +25,64 @@ func init() {
 
 	sealCmd.PersistentFlags().StringP("password-file", "p", "",
 		"The password file")
+	sealCmd.PersistentFlags().StringSlice("keyfile", []string{},
+		"Mix the contents of this file, of any size, into the password. Can be given multiple times.")
 	sealCmd.PersistentFlags().StringP("cipher", "i", "AES256",
 		"The cipher to encrypt with. Use the list command for a full list.")
+	sealCmd.PersistentFlags().StringP("kdf", "k", "ARGON2ID",
+		"The key derivation function to use. Use the list command for a full list.")
+	sealCmd.PersistentFlags().Bool("allow-legacy", false,
+		"Allow encrypting with a legacy cipher, which are decrypt-only by default")
+	sealCmd.PersistentFlags().StringSliceP("recipient", "r", []string{},
+		"Seal to this public key, or the public keys in this file, instead of a password. Can be given multiple times.")
+	sealCmd.PersistentFlags().StringSlice("ssh-recipient", []string{},
+		"Seal to this SSH public key, or the keys in this authorized_keys file. Can be given multiple times.")
+	sealCmd.PersistentFlags().Bool("ssh-agent", false,
+		"Seal to the keys held in the running ssh-agent, which can then open the file without a password")
+	sealCmd.PersistentFlags().String("encoding", "binary",
+		"How to write the sealed file: binary, base64, base64url, base32, hex or armor")
 	sealCmd.PersistentFlags().BoolP("encode-text", "t", false,
-		"encode the output in base64")
+		"encode the output as ASCII armored text")
+	sealCmd.PersistentFlags().MarkDeprecated("encode-text", "use --encoding armor instead")
 
 	viper.BindEnv("cipher")
+	viper.BindEnv("kdf")
+	viper.BindEnv("allow-legacy")
 	viper.BindEnv("password")
 	viper.BindEnv("password-file")
+	viper.BindEnv("keyfile")
+	viper.BindEnv("recipient")
+	viper.BindEnv("ssh-recipient")
+	viper.BindEnv("ssh-agent")
+	viper.BindEnv("encoding")
 	viper.BindEnv("encode-text")
 }
 
 func runSealPreRun(cmd *cobra.Command, args []string) {
 	viper.BindPFlag("cipher", cmd.PersistentFlags().Lookup("cipher"))
+	viper.BindPFlag("kdf", cmd.PersistentFlags().Lookup("kdf"))
+	viper.BindPFlag("allow-legacy", cmd.PersistentFlags().Lookup("allow-legacy"))
 	viper.BindPFlag("password-file", cmd.PersistentFlags().Lookup("password-file"))
+	viper.BindPFlag("keyfile", cmd.PersistentFlags().Lookup("keyfile"))
+	viper.BindPFlag("recipient", cmd.PersistentFlags().Lookup("recipient"))
+	viper.BindPFlag("ssh-recipient", cmd.PersistentFlags().Lookup("ssh-recipient"))
+	viper.BindPFlag("ssh-agent", cmd.PersistentFlags().Lookup("ssh-agent"))
+	viper.BindPFlag("encoding", cmd.PersistentFlags().Lookup("encoding"))
 	viper.BindPFlag("encode-text", cmd.PersistentFlags().Lookup("encode-text"))
 }
 func runSeal(cmd *cobra.Command, args []string) {
 	cipherType := cliGetCipherType()
-	password := cliGetPassword()
-	encodeText := viper.GetBool("encode-text")
+	sealOptions, closeAgent := cliGetSealOptions()
+	defer closeAgent()
+	password := cliGetSealPassword(sealOptions)
+	encoding := cliGetEncoding(crypto.EncodingBinary)
 
 	for _, file := range args {
 		cli.Debug("Encrypting %s", file)
-		err := encryptFile(cipherType, password, file, encodeText)
+		err := encryptFile(cipherType, password, sealOptions, file, encoding)
 		if err != nil {
-			if _, ok := err.(*crypto.DataIsEncryptedError); ok {
-				cli.Error("File is already encrypted, will not encrypt again")
+			if errors.Is(err, crypto.ErrAlreadyKrypt) {
+				cli.Error("%s is already encrypted, skipping", file)
 				continue
 			}
 			cli.Error("Could not encrypt %s", file)
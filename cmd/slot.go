@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/gesquive/cli"
+	"github.com/gesquive/krypt/crypto"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// slotCmd represents the slot command group
+var slotCmd = &cobra.Command{
+	Use:     "slot command",
+	Aliases: []string{"slots"},
+	Short:   "Manage the key slots of an encrypted file",
+	Long: `Manage the key slots of an encrypted file. Each slot holds the key of the file
+wrapped under a different password, keyfile or public key, and any one of them
+opens the file. Adding or removing a slot only rewrites the header of the file.`,
+}
+
+var slotListCmd = &cobra.Command{
+	Use:     "list FILE",
+	Aliases: []string{"ls", "l"},
+	Short:   "List the key slots of a file",
+	Long:    `List the key slots of a file. No password is needed to list them.`,
+	Args:    VerifyExactFileArgs(1),
+	Run:     runSlotList,
+}
+
+var slotAddCmd = &cobra.Command{
+	Use:     "add [flags] FILE",
+	Aliases: []string{"a"},
+	Short:   "Add a key slot to a file",
+	Long: `Add a key slot to a file. The file is opened with one of its current slots,
+then a slot for the new password is added, or for the recipients when any are
+given. A new password file holds a text password, surrounding whitespace is
+trimmed; use --new-keyfile to mix any file, binary or not, into the new password.`,
+	Args:   VerifyExactFileArgs(1),
+	PreRun: runSlotAddPreRun,
+	Run:    runSlotAdd,
+}
+
+var slotRemoveCmd = &cobra.Command{
+	Use:     "remove [flags] FILE SLOT",
+	Aliases: []string{"rm", "r"},
+	Short:   "Remove a key slot from a file",
+	Long: `Remove a key slot from a file. The file is opened with one of its slots, which
+may be the one removed. The last slot of a file cannot be removed.`,
+	Args:   cobra.ExactArgs(2),
+	PreRun: runSlotRemovePreRun,
+	Run:    runSlotRemove,
+}
+
+func init() {
+	RootCmd.AddCommand(slotCmd)
+	slotCmd.AddCommand(slotListCmd)
+	slotCmd.AddCommand(slotAddCmd)
+	slotCmd.AddCommand(slotRemoveCmd)
+
+	for _, cmd := range []*cobra.Command{slotAddCmd, slotRemoveCmd} {
+		cmd.Flags().StringP("password-file", "p", "",
+			"The password file to open the file with")
+		cmd.Flags().StringSlice("keyfile", []string{},
+			"The keyfile mixed into the password to open the file with. Can be given multiple times.")
+		cmd.Flags().StringSlice("identity", []string{},
+			"Open with the private keys in this file instead of a password. Can be given multiple times.")
+		cmd.Flags().StringSlice("ssh-identity", []string{},
+			"Open with this SSH private key file instead of a password. Can be given multiple times.")
+	}
+	slotAddCmd.Flags().StringP("new-password-file", "n", "",
+		"The file holding the password of the new slot")
+	slotAddCmd.Flags().StringSlice("new-keyfile", []string{},
+		"Mix the contents of this file, of any size, into the new password. Can be given multiple times.")
+	slotAddCmd.Flags().StringP("kdf", "k", "ARGON2ID",
+		"The key derivation function of the new slot. Use the list command for a full list.")
+	slotAddCmd.Flags().StringSliceP("recipient", "r", []string{},
+		"Add a slot for this public key, or the public keys in this file, instead of a password. Can be given multiple times.")
+	slotAddCmd.Flags().StringSlice("ssh-recipient", []string{},
+		"Add a slot for this SSH public key, or the keys in this authorized_keys file. Can be given multiple times.")
+	slotAddCmd.Flags().Bool("ssh-agent", false,
+		"Add a slot for each of the keys held in the running ssh-agent")
+
+	viper.BindEnv("password")
+	viper.BindEnv("password-file")
+	viper.BindEnv("new-password", "KRYPT_NEW_PASSWORD")
+	viper.BindEnv("new-password-file", "KRYPT_NEW_PASSWORD_FILE")
+	viper.BindEnv("keyfile")
+	viper.BindEnv("new-keyfile", "KRYPT_NEW_KEYFILE")
+	viper.BindEnv("kdf")
+	viper.BindEnv("identity")
+	viper.BindEnv("ssh-identity")
+	viper.BindEnv("recipient")
+	viper.BindEnv("ssh-recipient")
+	viper.BindEnv("ssh-agent")
+}
+
+// bindSlotOpenFlags binds the flags used to open the file
+func bindSlotOpenFlags(cmd *cobra.Command) {
+	viper.BindPFlag("password-file", cmd.Flags().Lookup("password-file"))
+	viper.BindPFlag("keyfile", cmd.Flags().Lookup("keyfile"))
+	viper.BindPFlag("identity", cmd.Flags().Lookup("identity"))
+	viper.BindPFlag("ssh-identity", cmd.Flags().Lookup("ssh-identity"))
+}
+
+// cliOpenSlots opens the key slots of a file, and exits when the file cannot
+// 	be opened or has no slots
+func cliOpenSlots(filePath string) *crypto.Envelope {
+	openOptions, closeAgent := cliGetOpenOptions()
+	defer closeAgent()
+	password := cliGetOpenPassword(openOptions)
+
+	envelope, err := readEnvelope(password, openOptions, filePath)
+	if err != nil {
+		os.Exit(cliOpenError(filePath, err))
+	}
+	if envelope == nil {
+		cli.Fatal("%s was sealed before key slots, use the reseal command to upgrade it", filePath)
+	}
+	return envelope
+}
+
+// cliWriteSlots writes the key slots of the envelope back to the file
+func cliWriteSlots(envelope *crypto.Envelope, filePath string) {
+	if err := rewrapFile(envelope, filePath, crypto.EncodingNone); err != nil {
+		cli.Debug("%v", err)
+		cli.Fatal("Could not write the key slots of %s", filePath)
+	}
+}
+
+func runSlotList(cmd *cobra.Command, args []string) {
+	filePath := args[0]
+	var stanzas []*crypto.Stanza
+	err := readSealed(filePath, func(r io.Reader) error {
+		var err error
+		stanzas, err = crypto.ReadStanzas(r)
+		return err
+	})
+	if errors.Is(err, crypto.ErrNotKrypt) {
+		cli.Fatal("%s is not encrypted, it has no key slots", filePath)
+	} else if err != nil {
+		cli.Debug("%v", err)
+		cli.Fatal("Could not read the key slots of %s", filePath)
+	}
+
+	if len(stanzas) == 0 {
+		cli.Info("%s was sealed with a single password, before key slots", filePath)
+		return
+	}
+	for i, stanza := range stanzas {
+		cli.Info("%-4d  %s", i, slotDescription(stanza))
+	}
+}
+
+// slotDescription describes the key slot held in a stanza
+func slotDescription(stanza *crypto.Stanza) string {
+	description := stanza.Type.String()
+	if crypto.PasswordStanzaKeyfile(stanza) {
+		description += "+keyfile"
+	}
+	if kdf, err := crypto.PasswordStanzaKDF(stanza); err == nil {
+		description += " (" + kdf.GetName() + ")"
+	}
+	if threshold, shares, err := crypto.SplitStanzaShares(stanza); err == nil {
+		description += fmt.Sprintf(" (%d of %d shares)", threshold, shares)
+	}
+	return description
+}
+
+func runSlotAddPreRun(cmd *cobra.Command, args []string) {
+	bindSlotOpenFlags(cmd)
+	viper.BindPFlag("new-password-file", cmd.Flags().Lookup("new-password-file"))
+	viper.BindPFlag("new-keyfile", cmd.Flags().Lookup("new-keyfile"))
+	viper.BindPFlag("kdf", cmd.Flags().Lookup("kdf"))
+	viper.BindPFlag("recipient", cmd.Flags().Lookup("recipient"))
+	viper.BindPFlag("ssh-recipient", cmd.Flags().Lookup("ssh-recipient"))
+	viper.BindPFlag("ssh-agent", cmd.Flags().Lookup("ssh-agent"))
+}
+
+func runSlotAdd(cmd *cobra.Command, args []string) {
+	filePath := args[0]
+	recipients, closeAgent := cliGetRecipients()
+	defer closeAgent()
+	kdf := cliGetKDF()
+	keyfile := cliGetKeyfile("new-keyfile")
+
+	envelope := cliOpenSlots(filePath)
+	if len(recipients) == 0 {
+		password := cliGetNamedPassword("new-password", "Enter new password: ")
+		recipients = append(recipients, crypto.NewPasswordRecipientWithKeyfile([]byte(password), keyfile, kdf))
+	}
+	for _, recipient := range recipients {
+		if err := envelope.AddRecipient(recipient); err != nil {
+			cli.Debug("%v", err)
+			cli.Fatal("Could not add a key slot to %s", filePath)
+		}
+	}
+	cliWriteSlots(envelope, filePath)
+}
+
+func runSlotRemovePreRun(cmd *cobra.Command, args []string) {
+	bindSlotOpenFlags(cmd)
+}
+
+func runSlotRemove(cmd *cobra.Command, args []string) {
+	filePath := args[0]
+	slot, err := strconv.Atoi(args[1])
+	if err != nil {
+		cli.Fatal("Invalid slot \"%s\", use the slot number from slot list", args[1])
+	}
+
+	envelope := cliOpenSlots(filePath)
+	slots := len(envelope.Stanzas())
+	if slot < 0 || slot >= slots {
+		cli.Fatal("%s has no slot %d", filePath, slot)
+	}
+	if slots == 1 {
+		cli.Fatal("Cannot remove the last key slot of %s", filePath)
+	}
+	if err := envelope.RemoveStanza(slot); err != nil {
+		cli.Fatal("Could not remove slot %d: %v", slot, err)
+	}
+	cliWriteSlots(envelope, filePath)
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gesquive/cli"
+	"github.com/gesquive/krypt/crypto"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// splitCmd represents the split command
+var splitCmd = &cobra.Command{
+	Use:     "split [flags] FILE",
+	Aliases: []string{"sp"},
+	Short:   "Split the key of an encrypted file into shares",
+	Long: `Split the key of an encrypted file into shares, any threshold of which open the
+file together with the --share flag. The file is opened with one of its key
+slots, then a slot for the shares is added. The shares are written to
+FILE.share1 to FILE.shareN, or printed with --print to be handed out like
+passwords.`,
+	ValidArgs: []string{"FILE"},
+	Args:      VerifyExactFileArgs(1),
+	PreRun:    runSplitPreRun,
+	Run:       runSplit,
+}
+
+func init() {
+	RootCmd.AddCommand(splitCmd)
+
+	splitCmd.Flags().IntP("shares", "s", 5,
+		"The number of shares to split the key into")
+	splitCmd.Flags().IntP("threshold", "t", 3,
+		"The number of shares needed to open the file")
+	splitCmd.Flags().Bool("print", false,
+		"Print the shares instead of writing share files")
+	splitCmd.Flags().StringP("password-file", "p", "",
+		"The password file to open the file with")
+	splitCmd.Flags().StringSlice("keyfile", []string{},
+		"The keyfile mixed into the password to open the file with. Can be given multiple times.")
+	splitCmd.Flags().StringSlice("identity", []string{},
+		"Open with the private keys in this file instead of a password. Can be given multiple times.")
+	splitCmd.Flags().StringSlice("ssh-identity", []string{},
+		"Open with this SSH private key file instead of a password. Can be given multiple times.")
+
+	viper.BindEnv("shares")
+	viper.BindEnv("threshold")
+	viper.BindEnv("password")
+	viper.BindEnv("password-file")
+	viper.BindEnv("keyfile")
+	viper.BindEnv("identity")
+	viper.BindEnv("ssh-identity")
+}
+
+func runSplitPreRun(cmd *cobra.Command, args []string) {
+	viper.BindPFlag("shares", cmd.Flags().Lookup("shares"))
+	viper.BindPFlag("threshold", cmd.Flags().Lookup("threshold"))
+	viper.BindPFlag("print", cmd.Flags().Lookup("print"))
+	viper.BindPFlag("password-file", cmd.Flags().Lookup("password-file"))
+	viper.BindPFlag("keyfile", cmd.Flags().Lookup("keyfile"))
+	viper.BindPFlag("identity", cmd.Flags().Lookup("identity"))
+	viper.BindPFlag("ssh-identity", cmd.Flags().Lookup("ssh-identity"))
+}
+
+func runSplit(cmd *cobra.Command, args []string) {
+	filePath := args[0]
+	shareCount := viper.GetInt("shares")
+	threshold := viper.GetInt("threshold")
+	printShares := viper.GetBool("print")
+
+	recipient, shares, err := crypto.SplitKey(shareCount, threshold)
+	if err != nil {
+		cli.Fatal("Could not split the key: %v", err)
+	}
+	sharePaths := make([]string, len(shares))
+	for i := range shares {
+		sharePaths[i] = fmt.Sprintf("%s.share%d", filePath, i+1)
+		if _, err := os.Stat(sharePaths[i]); !printShares && !os.IsNotExist(err) {
+			cli.Fatal("%s already exists", sharePaths[i])
+		}
+	}
+
+	envelope := cliOpenSlots(filePath)
+	if err := envelope.AddRecipient(recipient); err != nil {
+		cli.Debug("%v", err)
+		cli.Fatal("Could not add a key slot to %s", filePath)
+	}
+
+	if printShares {
+		cliWriteSlots(envelope, filePath)
+		for _, share := range shares {
+			cli.Info("%s", share)
+		}
+		return
+	}
+
+	for i, share := range shares {
+		content := shareFile(filepath.Base(filePath), i+1, len(shares), threshold, share)
+		if err := writeKeyFile(sharePaths[i], content); err != nil {
+			removeFiles(sharePaths[:i])
+			cli.Fatal("Could not write %s: %v", sharePaths[i], err)
+		}
+	}
+	if err := rewrapFile(envelope, filePath, crypto.EncodingNone); err != nil {
+		removeFiles(sharePaths)
+		cli.Debug("%v", err)
+		cli.Fatal("Could not write the key slots of %s", filePath)
+	}
+	for _, sharePath := range sharePaths {
+		cli.Info("%s", sharePath)
+	}
+}
+
+// shareFile returns the contents of a share file
+func shareFile(name string, n int, shares int, threshold int, share string) []byte {
+	buffer := new(bytes.Buffer)
+	fmt.Fprintf(buffer, "# krypt share %d of %d for %s, %d shares open it\n", n, shares, name, threshold)
+	fmt.Fprintf(buffer, "# created: %s\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(buffer, "%s\n", share)
+	return buffer.Bytes()
+}
+
+// readShare returns the share held in the contents of a share file
+func readShare(content []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return line, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("not a share file")
+}
+
+// removeFiles removes the files, ignoring errors
+func removeFiles(filePaths []string) {
+	for _, filePath := range filePaths {
+		os.Remove(filePath)
+	}
+}
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"os"
+
 	"github.com/gesquive/cli"
 	"github.com/gesquive/krypt/crypto"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -24,29 +27,53 @@ func init() {
 
 	unsealCmd.PersistentFlags().StringP("password-file", "p", "",
 		"The password file")
+	unsealCmd.PersistentFlags().StringSlice("keyfile", []string{},
+		"Mix the contents of this file, of any size, into the password. Can be given multiple times.")
+	unsealCmd.PersistentFlags().StringSlice("identity", []string{},
+		"Open with the private keys in this file instead of a password. Can be given multiple times.")
+	unsealCmd.PersistentFlags().StringSlice("ssh-identity", []string{},
+		"Open with this SSH private key file instead of a password. Can be given multiple times.")
+	unsealCmd.PersistentFlags().StringSlice("share", []string{},
+		"Open with this share, or share file, from the split command. Give as many as the split needs.")
 
 	viper.BindEnv("password")
 	viper.BindEnv("password-file")
+	viper.BindEnv("keyfile")
+	viper.BindEnv("identity")
+	viper.BindEnv("ssh-identity")
+	viper.BindEnv("share")
 }
 
 func runUnsealPreRun(cmd *cobra.Command, args []string) {
 	viper.BindPFlag("password-file", cmd.PersistentFlags().Lookup("password-file"))
+	viper.BindPFlag("keyfile", cmd.PersistentFlags().Lookup("keyfile"))
+	viper.BindPFlag("identity", cmd.PersistentFlags().Lookup("identity"))
+	viper.BindPFlag("ssh-identity", cmd.PersistentFlags().Lookup("ssh-identity"))
+	viper.BindPFlag("share", cmd.PersistentFlags().Lookup("share"))
 }
 
 func runUnseal(cmd *cobra.Command, args []string) {
-	password := cliGetPassword()
+	openOptions, closeAgent := cliGetOpenOptions()
+	defer closeAgent()
+	password := cliGetOpenPassword(openOptions)
 
+	exitCode := 0
 	for _, file := range args {
 		// TODO: use glob to expand file paths
 		cli.Debug("Decrypting %s", file)
-		_, err := decryptFile(password, file)
+		_, err := decryptFile(password, openOptions, file)
 		if err != nil {
-			if _, ok := err.(*crypto.DataIsNotEncryptedError); ok {
-				cli.Error("File is not encrypted, cannot decrypt")
+			if errors.Is(err, crypto.ErrNotKrypt) {
+				cli.Error("%s is not encrypted, skipping", file)
 				continue
 			}
-			cli.Error("Could not decrypt %s", file)
-			cli.Debug("%v", err)
+			code := cliOpenError(file, err)
+			if exitCode == 0 {
+				exitCode = code
+			}
 		}
 	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
 }
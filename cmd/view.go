@@ -1,8 +1,8 @@
 package cmd
 
 import (
-	"github.com/gesquive/cli"
-	"github.com/gesquive/krypt/crypto"
+	"os"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -26,31 +26,43 @@ func init() {
 	viewCmd.PersistentFlags().StringP("editor", "e", "", "The editor to use")
 	viewCmd.PersistentFlags().StringP("password-file", "p", "",
 		"The password file")
+	viewCmd.PersistentFlags().StringSlice("keyfile", []string{},
+		"Mix the contents of this file, of any size, into the password. Can be given multiple times.")
+	viewCmd.PersistentFlags().StringSlice("identity", []string{},
+		"Open with the private keys in this file instead of a password. Can be given multiple times.")
+	viewCmd.PersistentFlags().StringSlice("ssh-identity", []string{},
+		"Open with this SSH private key file instead of a password. Can be given multiple times.")
+	viewCmd.PersistentFlags().StringSlice("share", []string{},
+		"Open with this share, or share file, from the split command. Give as many as the split needs.")
 
 	viper.BindEnv("editor")
 	viper.BindEnv("password")
 	viper.BindEnv("password-file")
+	viper.BindEnv("keyfile")
+	viper.BindEnv("identity")
+	viper.BindEnv("ssh-identity")
+	viper.BindEnv("share")
 }
 
 func runViewPreRun(cmd *cobra.Command, args []string) {
 	viper.BindPFlag("editor", cmd.PersistentFlags().Lookup("editor"))
 	viper.BindPFlag("password-file", cmd.PersistentFlags().Lookup("password-file"))
+	viper.BindPFlag("keyfile", cmd.PersistentFlags().Lookup("keyfile"))
+	viper.BindPFlag("identity", cmd.PersistentFlags().Lookup("identity"))
+	viper.BindPFlag("ssh-identity", cmd.PersistentFlags().Lookup("ssh-identity"))
+	viper.BindPFlag("share", cmd.PersistentFlags().Lookup("share"))
 }
 
 func runView(cmd *cobra.Command, args []string) {
-	password := cliGetPassword()
+	openOptions, closeAgent := cliGetOpenOptions()
+	defer closeAgent()
+	password := cliGetOpenPassword(openOptions)
 	editor := cliGetEditor()
 
 	file := args[0]
-	plainText, _, err := readCrypt(password, file)
+	plainText, _, _, err := readCrypt(password, openOptions, file)
 	if err != nil {
-		if _, ok := err.(*crypto.DataIsNotEncryptedError); ok {
-			cli.Error("File is not encrypted, cannot decrypt")
-			return
-		}
-		cli.Error("Could not decrypt file '%s'", file)
-		cli.Debug("%v", err)
-		return
+		os.Exit(cliOpenError(file, err))
 	}
 
 	cliRunFileEdit(editor, plainText)
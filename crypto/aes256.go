@@ -3,13 +3,8 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
-	"crypto/sha256"
-	"io"
 
 	"github.com/pkg/errors"
-
-	"golang.org/x/crypto/pbkdf2"
 )
 
 const aes256Name = "AES256"
@@ -46,18 +41,13 @@ func (c *AES256Cipher) GetType() CipherType {
 	return c.cipherType
 }
 
-// Encrypt data using AES256-GCM cipher. This both hides the content of
-// the data and provides a check that it hasn't been altered. Output takes the
-// form nonce|ciphertext|tag|salt where '|' indicates concatenation.
-func (c *AES256Cipher) Encrypt(data []byte, password []byte) ([]byte, error) {
-	// we need the salt as random as possible
-	salt := make([]byte, defaultSaltSize)
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
-		return nil, errors.Wrapf(err, "randomizing salt")
-	}
-	// derive a key from password using HMAC-SHA-256 based PBKDF2 key derivation function
-	key := pbkdf2.Key(password, salt, 4096, 32, sha256.New)
+// GetInfo returns the block size, mode and speed of the cipher
+func (c *AES256Cipher) GetInfo() CipherInfo {
+	return CipherInfo{KeySize: keySize, BlockSize: aes.BlockSize, Mode: "GCM", AEAD: true, Speed: SpeedFast}
+}
 
+// NewAEAD returns the AES256-GCM mode cipher keyed with key
+func (c *AES256Cipher) NewAEAD(key []byte) (cipher.AEAD, error) {
 	blockCipher, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, errors.Wrapf(err, "creating block cipher")
@@ -67,45 +57,16 @@ func (c *AES256Cipher) Encrypt(data []byte, password []byte) ([]byte, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "creating block mode cipher")
 	}
+	return modeCipher, nil
+}
 
-	nonce := make([]byte, modeCipher.NonceSize())
-	_, err = io.ReadFull(rand.Reader, nonce)
-	if err != nil {
-		return nil, errors.Wrapf(err, "randomizing nonce")
-	}
-
-	ciphertext := modeCipher.Seal(nonce, nonce, data, nil)
-	ciphertext = append(ciphertext, salt...)
-
-	return ciphertext, nil
+// Encrypt data in one piece the way version 1 krypt data was sealed, see
+// encryptV1
+func (c *AES256Cipher) Encrypt(data []byte, password []byte) ([]byte, error) {
+	return encryptV1(c, data, password)
 }
 
-// Decrypt data using AES256-GCM cipher. This both hides the content of
-// the data and provides a check that it hasn't been altered. Expects input
-// form nonce|ciphertext|tag|salt where '|' indicates concatenation.
+// Decrypt data sealed in one piece by version 1 krypt, see decryptV1
 func (c *AES256Cipher) Decrypt(data []byte, password []byte) ([]byte, error) {
-	salt := data[len(data)-defaultSaltSize:]
-
-	key := pbkdf2.Key(password, salt, 4096, 32, sha256.New)
-
-	blockCipher, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, errors.Wrapf(err, "creating block cipher")
-	}
-
-	modeCipher, err := cipher.NewGCM(blockCipher)
-	if err != nil {
-		return nil, errors.Wrapf(err, "creating block mode cipher")
-	}
-
-	if len(data) < modeCipher.NonceSize() {
-		return nil, errors.New("malformed ciphertext")
-	}
-
-	plaintext, err := modeCipher.Open(nil, data[:modeCipher.NonceSize()], data[modeCipher.NonceSize():len(data)-defaultSaltSize], nil)
-	if err != nil {
-		return nil, errors.Wrapf(err, "decrypting data")
-	}
-
-	return plaintext, nil
+	return decryptV1(c, data, password)
 }
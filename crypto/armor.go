@@ -0,0 +1,310 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Armored data takes the form
+//
+//	-----BEGIN KRYPT MESSAGE-----
+//	Key: value
+//
+//	base64 of the sealed data, wrapped at 64 columns
+//	=checksum
+//	-----END KRYPT MESSAGE-----
+//
+// like PEM and OpenPGP armor. The headers and the blank line after them are
+// optional, and are not authenticated. The checksum is the base64 of the
+// CRC-24 of the data, as in OpenPGP, and catches damage from copying the text
+// before the data is opened.
+const (
+	armorBegin     = "-----BEGIN KRYPT MESSAGE-----"
+	armorEnd       = "-----END KRYPT MESSAGE-----"
+	armorLineWidth = 64
+)
+
+// limits on armored data, used to reject garbage before reading all of it
+const (
+	maxArmorHeaders    = 64
+	maxArmorLineLength = 4096
+)
+
+// armorWriter armors everything written to it
+type armorWriter struct {
+	writer  io.Writer
+	lines   *lineWriter
+	encoder io.WriteCloser
+	crc     uint32
+	closed  bool
+}
+
+// NewArmorWriter returns a writer that armors everything written to it and
+// writes the result to w, starting with the headers sorted by key. Close must
+// be called to write the checksum and end line; it does not close w.
+func NewArmorWriter(w io.Writer, headers map[string]string) (io.WriteCloser, error) {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString(armorBegin + "\n")
+	if len(headers) > 0 {
+		keys := make([]string, 0, len(headers))
+		for key := range headers {
+			if !validArmorHeader(key, headers[key]) {
+				return nil, errors.Errorf("invalid armor header %q", key)
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			buffer.WriteString(key + ": " + headers[key] + "\n")
+		}
+		buffer.WriteString("\n")
+	}
+	if _, err := w.Write(buffer.Bytes()); err != nil {
+		return nil, errors.Wrap(err, "writing armor")
+	}
+
+	lines := &lineWriter{writer: w}
+	return &armorWriter{
+		writer:  w,
+		lines:   lines,
+		encoder: base64.NewEncoder(base64.StdEncoding, lines),
+		crc:     crc24Init,
+	}, nil
+}
+
+// Write encodes p
+func (a *armorWriter) Write(p []byte) (int, error) {
+	if a.closed {
+		return 0, errors.New("write to closed armor")
+	}
+	a.crc = crc24(a.crc, p)
+	return a.encoder.Write(p)
+}
+
+// Close encodes the remaining data and writes the checksum and end line
+func (a *armorWriter) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	if err := a.encoder.Close(); err != nil {
+		return errors.Wrap(err, "writing armor")
+	}
+	if a.lines.column > 0 {
+		if _, err := a.writer.Write([]byte("\n")); err != nil {
+			return errors.Wrap(err, "writing armor")
+		}
+	}
+	checksum := []byte{byte(a.crc >> 16), byte(a.crc >> 8), byte(a.crc)}
+	tail := "=" + base64.StdEncoding.EncodeToString(checksum) + "\n" + armorEnd + "\n"
+	if _, err := a.writer.Write([]byte(tail)); err != nil {
+		return errors.Wrap(err, "writing armor")
+	}
+	return nil
+}
+
+// lineWriter breaks what is written to it into lines of armorLineWidth
+type lineWriter struct {
+	writer io.Writer
+	column int
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := armorLineWidth - l.column
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := l.writer.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		l.column += n
+		p = p[n:]
+		if l.column == armorLineWidth {
+			if _, err := l.writer.Write([]byte("\n")); err != nil {
+				return written, err
+			}
+			l.column = 0
+		}
+	}
+	return written, nil
+}
+
+// armorReader decodes armored data a line at a time
+type armorReader struct {
+	reader  *bufio.Reader
+	pending []byte
+	decoded []byte
+	crc     uint32
+	done    bool
+	err     error
+}
+
+// NewArmorReader returns a reader of the data armored in r, and the headers of
+// the armor. The checksum is checked once all of the data has been read, and
+// a mismatch is a TamperedError. Armor that ends early is a TruncatedError.
+func NewArmorReader(r io.Reader) (io.Reader, map[string]string, error) {
+	reader := bufio.NewReader(r)
+	line, err := readArmorLine(reader)
+	for err == nil && len(line) == 0 {
+		line, err = readArmorLine(reader)
+	}
+	if err != nil || line != armorBegin {
+		return nil, nil, NewDataIsNotEncryptedError()
+	}
+
+	a := &armorReader{reader: reader, crc: crc24Init}
+	headers := map[string]string{}
+	for {
+		line, err := readArmorLine(reader)
+		if err != nil {
+			return nil, nil, armorReadError(err)
+		}
+		index := strings.Index(line, ": ")
+		if index <= 0 {
+			// without a blank line after them, the headers end at the data
+			if len(line) > 0 {
+				if err := a.addLine(line); err != nil {
+					return nil, nil, err
+				}
+			}
+			break
+		}
+		if len(headers) == maxArmorHeaders {
+			return nil, nil, &TamperedError{"too many armor headers"}
+		}
+		headers[line[:index]] = line[index+2:]
+	}
+	return a, headers, nil
+}
+
+// Read returns decoded data
+func (a *armorReader) Read(p []byte) (int, error) {
+	for len(a.decoded) == 0 {
+		if a.err != nil {
+			return 0, a.err
+		}
+		if a.done {
+			return 0, io.EOF
+		}
+		a.err = a.readLine()
+	}
+	n := copy(p, a.decoded)
+	a.decoded = a.decoded[n:]
+	return n, nil
+}
+
+// readLine decodes the next line of the armor
+func (a *armorReader) readLine() error {
+	line, err := readArmorLine(a.reader)
+	if err != nil {
+		return armorReadError(err)
+	}
+	switch {
+	case line == armorEnd:
+		return a.finish(nil)
+	case strings.HasPrefix(line, "="):
+		checksum, err := base64.StdEncoding.DecodeString(line[1:])
+		if err != nil || len(checksum) != 3 {
+			return &TamperedError{"armor checksum is malformed"}
+		}
+		end, err := readArmorLine(a.reader)
+		if err != nil {
+			return armorReadError(err)
+		}
+		if end != armorEnd {
+			return &TamperedError{"armor is malformed"}
+		}
+		return a.finish(checksum)
+	}
+	return a.addLine(line)
+}
+
+// addLine decodes the whole base64 quanta of a line of data
+func (a *armorReader) addLine(line string) error {
+	a.pending = append(a.pending, line...)
+	n := len(a.pending) / 4 * 4
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(n))
+	decodedLen, err := base64.StdEncoding.Decode(decoded, a.pending[:n])
+	if err != nil {
+		return &TamperedError{"armor is malformed"}
+	}
+	a.pending = append(a.pending[:0], a.pending[n:]...)
+	a.decoded = decoded[:decodedLen]
+	a.crc = crc24(a.crc, a.decoded)
+	return nil
+}
+
+// finish checks the end of the armor against the checksum, if there is one
+func (a *armorReader) finish(checksum []byte) error {
+	if len(a.pending) > 0 {
+		return &TamperedError{"armor is malformed"}
+	}
+	if checksum != nil && !bytes.Equal(checksum, []byte{byte(a.crc >> 16), byte(a.crc >> 8), byte(a.crc)}) {
+		return &TamperedError{"armor checksum mismatch"}
+	}
+	a.done = true
+	return nil
+}
+
+// readArmorLine reads a line of armor without its line ending and surrounding
+// whitespace
+func readArmorLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull || len(line) > maxArmorLineLength {
+		return "", &TamperedError{"armor line too long"}
+	}
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(line)), nil
+}
+
+// armorReadError returns the error for armor that could not be read
+func armorReadError(err error) error {
+	if err == io.EOF {
+		return &TruncatedError{"armor is truncated"}
+	}
+	if _, ok := err.(*TamperedError); ok {
+		return err
+	}
+	return errors.Wrap(err, "reading armor")
+}
+
+// validArmorHeader returns whether a header can be written in the armor
+func validArmorHeader(key string, value string) bool {
+	if len(key) == 0 || strings.ContainsAny(key, ":\r\n") || strings.TrimSpace(key) != key {
+		return false
+	}
+	return !strings.ContainsAny(value, "\r\n") && strings.TrimSpace(value) == value
+}
+
+// CRC-24 as used by OpenPGP armor, see RFC 4880 section 6.1
+const (
+	crc24Init = 0xb704ce
+	crc24Poly = 0x1864cfb
+)
+
+func crc24(crc uint32, data []byte) uint32 {
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crc24Poly
+			}
+		}
+	}
+	return crc & 0xffffff
+}
@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func armorData(t *testing.T, data []byte, headers map[string]string) string {
+	buffer := new(bytes.Buffer)
+	writer, err := NewArmorWriter(buffer, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
+
+func TestArmor(t *testing.T) {
+	for _, size := range []int{0, 1, 2, 3, 47, 48, 49, 1000, 3*defaultChunkSize + 7} {
+		data := bytes.Repeat([]byte("armor me"), size/8+1)[:size]
+		armored := armorData(t, data, map[string]string{"Comment": "test data", "Cipher": "AES256"})
+
+		lines := strings.Split(strings.TrimSuffix(armored, "\n"), "\n")
+		assert.Equal(t, armorBegin, lines[0], "%d: begin line mismatch", size)
+		assert.Equal(t, "Cipher: AES256", lines[1], "%d: headers should be sorted", size)
+		assert.Equal(t, "Comment: test data", lines[2], "%d: header mismatch", size)
+		assert.Equal(t, "", lines[3], "%d: headers should end with a blank line", size)
+		assert.Equal(t, armorEnd, lines[len(lines)-1], "%d: end line mismatch", size)
+		assert.True(t, strings.HasPrefix(lines[len(lines)-2], "="), "%d: checksum missing", size)
+		for _, line := range lines {
+			assert.True(t, len(line) <= armorLineWidth, "%d: line too long", size)
+		}
+
+		reader, headers, err := NewArmorReader(strings.NewReader(armored))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]string{"Comment": "test data", "Cipher": "AES256"}, headers, "%d: headers mismatch", size)
+		decoded, err := ioutil.ReadAll(reader)
+		assert.NoError(t, err, "%d: read failed", size)
+		assert.Equal(t, data, decoded, "%d: data mismatch", size)
+	}
+}
+
+func TestArmorLenient(t *testing.T) {
+	data := []byte("This is the test data to compare, long enough to wrap a line of armor")
+	armored := armorData(t, data, nil)
+	assert.False(t, strings.Contains(armored, "\n\n"), "armor without headers should have no blank line")
+
+	// whitespace, CRLF line endings and other line widths are read
+	loose := "\n  " + strings.Replace(armored, "\n", "\r\n", -1)
+	lines := strings.Split(armored, "\n")
+	rewrapped := strings.Join([]string{lines[0], lines[1][:10], lines[1][10:] + lines[2], lines[3], lines[4]}, "\n")
+	// the checksum is optional
+	unchecked := strings.Join([]string{lines[0], lines[1], lines[2], lines[4]}, "\n")
+	for _, text := range []string{loose, rewrapped, unchecked} {
+		reader, _, err := NewArmorReader(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := ioutil.ReadAll(reader)
+		assert.NoError(t, err, "read failed")
+		assert.Equal(t, data, decoded, "data mismatch")
+	}
+}
+
+func TestArmorErrors(t *testing.T) {
+	data := []byte("This is the test data to compare, long enough to wrap a line of armor")
+	armored := armorData(t, data, nil)
+	lines := strings.Split(armored, "\n")
+	altered := []byte(armored)
+	altered[len(armorBegin)+5] ^= 0x01
+
+	tests := []struct {
+		name   string
+		text   string
+		target error
+	}{
+		{"no end", strings.Join(lines[:3], "\n"), ErrTruncated},
+		{"no data", lines[0], ErrTruncated},
+		{"altered", string(altered), ErrTampered},
+		{"bad checksum", strings.Replace(armored, lines[3], "=AAAA", 1), ErrTampered},
+		{"bad base64", strings.Replace(armored, lines[1], "!"+lines[1][1:], 1), ErrTampered},
+		{"partial quantum", strings.Replace(armored, lines[2], lines[2][1:], 1), ErrTampered},
+	}
+	for _, test := range tests {
+		reader, _, err := NewArmorReader(strings.NewReader(test.text))
+		if err == nil {
+			_, err = ioutil.ReadAll(reader)
+		}
+		assert.True(t, errors.Is(err, test.target), "%s: expected %v, got %v", test.name, test.target, err)
+	}
+
+	_, _, err := NewArmorReader(strings.NewReader("This is not armor"))
+	assert.True(t, errors.Is(err, ErrNotKrypt), "plain text: got %v", err)
+	_, err = NewArmorWriter(new(bytes.Buffer), map[string]string{"Bad:Key": "value"})
+	assert.Error(t, err, "invalid header key should fail")
+	_, err = NewArmorWriter(new(bytes.Buffer), map[string]string{"Key": "two\nlines"})
+	assert.Error(t, err, "invalid header value should fail")
+}
+
+func TestArmorSealed(t *testing.T) {
+	pass := []byte("geronimo")
+	data := []byte("This is the test data to compare")
+	sealed, err := EncryptWithOptions(AES256, pass, data, Options{KDF: NewPBKDF2KDF(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	armored := armorData(t, sealed, nil)
+	assert.Equal(t, EncodingArmor, Inspect([]byte(armored)), "encoding mismatch")
+
+	reader, _, err := NewArmorReader(strings.NewReader(armored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainText, err := NewDecryptReader(reader, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := ioutil.ReadAll(plainText)
+	assert.NoError(t, err, "decrypt failed")
+	assert.Equal(t, data, decrypted, "data mismatch")
+}
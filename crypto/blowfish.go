@@ -0,0 +1,64 @@
+package crypto
+
+import (
+	"crypto/cipher"
+
+	"golang.org/x/crypto/blowfish"
+)
+
+const blowfishName = "BLOWFISH"
+
+// BlowfishCipher encrypts using Blowfish in CTR mode with HMAC-SHA256. It is a legacy
+// cipher, kept to open data from old systems, see DecryptOpenSSL; its 64-bit
+// block makes it unfit for large amounts of data.
+type BlowfishCipher struct {
+	description string
+	name        string
+	cipherType  CipherType
+}
+
+// NewBlowfishCipher constructor
+func NewBlowfishCipher() *BlowfishCipher {
+	r := &BlowfishCipher{}
+	r.description = "Blowfish-CTR-HMAC-SHA256 cipher (legacy)"
+	r.name = blowfishName
+	r.cipherType = BLOWFISH
+
+	return r
+}
+
+// GetDescription returns description string
+func (c *BlowfishCipher) GetDescription() string {
+	return c.description
+}
+
+// GetName returns name string
+func (c *BlowfishCipher) GetName() string {
+	return c.name
+}
+
+// GetType returns CryptType
+func (c *BlowfishCipher) GetType() CipherType {
+	return c.cipherType
+}
+
+// GetInfo returns the block size, mode and speed of the cipher
+func (c *BlowfishCipher) GetInfo() CipherInfo {
+	return CipherInfo{KeySize: keySize, BlockSize: blowfish.BlockSize, Mode: "CTR+HMAC-SHA256", Speed: SpeedModerate}
+}
+
+// IsLegacy reports that this cipher should only be used to open old data
+func (c *BlowfishCipher) IsLegacy() bool {
+	return true
+}
+
+// NewAEAD returns Blowfish in encrypt-then-MAC form keyed with key
+func (c *BlowfishCipher) NewAEAD(key []byte) (cipher.AEAD, error) {
+	return newEtmAEAD(c.name, key, keySize, func(encKey []byte) (cipher.Block, error) {
+		return newBlowfishBlock(encKey)
+	})
+}
+
+func newBlowfishBlock(key []byte) (cipher.Block, error) {
+	return blowfish.NewCipher(key)
+}
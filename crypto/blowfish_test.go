@@ -0,0 +1,34 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlowfishEncrypt(t *testing.T) {
+	cipher := NewBlowfishCipher()
+
+	data := []byte(`There is a theory which states that if ever anyone discovers
+exactly what the Universe is for and why it is here, it will
+instantly disappear and be replaced by something even more
+bizarre and inexplicable. There is another theory which states
+that this has already happened.`)
+	key := bytes.Repeat([]byte{0x42}, keySize)
+
+	modeCipher, err := cipher.NewAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, modeCipher.NonceSize())
+	encryptedData := modeCipher.Seal(nil, nonce, data, nil)
+	assert.NotEmpty(t, encryptedData, "no encrypted data")
+	assert.False(t, bytes.Compare(data, encryptedData[:len(data)]) == 0, "encrypted data should be different")
+
+	decryptedData, err := modeCipher.Open(nil, nonce, encryptedData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data, decryptedData, "decrypted data does not match original data")
+}
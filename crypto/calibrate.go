@@ -0,0 +1,145 @@
+package crypto
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lower bounds for calibrated parameters, we never go below these even on
+// very slow machines
+const (
+	minArgon2idMemory     = 8 * 1024 // KiB
+	maxArgon2idThreads    = 4
+	minScryptLogN         = 10
+	minPBKDF2Iterations   = 10000
+	calibrationIterations = 10000
+)
+
+var calibrationPassword = []byte("krypt calibration password")
+var calibrationSalt = []byte("krypt calibration salt")
+
+// CalibrateKDF benchmarks the given kdf type on this machine and returns the
+// kdf with parameters that take about target to derive a key while using no
+// more than maxMemory bytes. Memory-hard kdfs use as much of the memory budget
+// as the target allows.
+func CalibrateKDF(kdfType KDFType, target time.Duration, maxMemory uint64) (KDF, error) {
+	if target <= 0 {
+		return nil, errors.New("calibration target must be positive")
+	}
+
+	switch kdfType {
+	case ARGON2ID:
+		return calibrateArgon2id(target, maxMemory)
+	case SCRYPT:
+		return calibrateScrypt(target, maxMemory)
+	case PBKDF2:
+		return calibratePBKDF2(target)
+	default:
+		return nil, NewUnknownKDFTypeError()
+	}
+}
+
+// calibrateArgon2id picks the largest memory cost that fits the budget and the
+// target with a single pass, and then adds passes until the target is reached
+func calibrateArgon2id(target time.Duration, maxMemory uint64) (KDF, error) {
+	threads := runtime.NumCPU()
+	if threads > maxArgon2idThreads {
+		threads = maxArgon2idThreads
+	}
+
+	memory := maxMemory / 1024
+	if memory > maxKDFMemory/1024 {
+		memory = maxKDFMemory / 1024
+	}
+	if memory < minArgon2idMemory {
+		return nil, errors.New("memory budget too small for argon2id")
+	}
+
+	kdf := NewArgon2idKDF(1, uint32(memory), uint8(threads))
+	elapsed, err := timeKDF(kdf)
+	if err != nil {
+		return nil, err
+	}
+	for elapsed > target && kdf.Memory/2 >= minArgon2idMemory {
+		kdf.Memory /= 2
+		if elapsed, err = timeKDF(kdf); err != nil {
+			return nil, err
+		}
+	}
+
+	if elapsed <= 0 {
+		elapsed = time.Nanosecond
+	}
+	if passes := target / elapsed; passes > 1 {
+		if passes > maxKDFTime {
+			passes = maxKDFTime
+		}
+		kdf.Time = uint32(passes)
+	}
+	return kdf, nil
+}
+
+// calibrateScrypt raises the cost parameter N for as long as the derivation
+// stays within both the target and the memory budget
+func calibrateScrypt(target time.Duration, maxMemory uint64) (KDF, error) {
+	kdf := NewScryptKDF(minScryptLogN, defaultScryptR, defaultScryptP)
+	if scryptMemory(kdf) > maxMemory {
+		return nil, errors.New("memory budget too small for scrypt")
+	}
+
+	elapsed, err := timeKDF(kdf)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		next := NewScryptKDF(kdf.LogN+1, kdf.R, kdf.P)
+		// every step doubles the time taken, stop before passing the target
+		if scryptMemory(next) > maxMemory || scryptMemory(next) > maxKDFMemory || 2*elapsed > target {
+			break
+		}
+		if elapsed, err = timeKDF(next); err != nil {
+			return nil, err
+		}
+		if elapsed > target {
+			break
+		}
+		kdf = next
+	}
+	return kdf, nil
+}
+
+// calibratePBKDF2 scales the iteration count from a timed sample
+func calibratePBKDF2(target time.Duration) (KDF, error) {
+	elapsed, err := timeKDF(NewPBKDF2KDF(calibrationIterations))
+	if err != nil {
+		return nil, err
+	}
+	if elapsed <= 0 {
+		elapsed = time.Nanosecond
+	}
+
+	iterations := uint64(target) * calibrationIterations / uint64(elapsed)
+	if iterations < minPBKDF2Iterations {
+		iterations = minPBKDF2Iterations
+	}
+	if iterations > maxKDFTime {
+		iterations = maxKDFTime
+	}
+	return NewPBKDF2KDF(uint32(iterations)), nil
+}
+
+// scryptMemory returns the bytes used by scrypt with the given parameters
+func scryptMemory(kdf *ScryptKDF) uint64 {
+	return uint64(128) * uint64(kdf.R) << kdf.LogN
+}
+
+// timeKDF measures how long the kdf takes to derive a key
+func timeKDF(kdf KDF) (time.Duration, error) {
+	start := time.Now()
+	if _, err := kdf.DeriveKey(calibrationPassword, calibrationSalt, keySize); err != nil {
+		return 0, errors.Wrapf(err, "benchmarking %s", kdf.GetName())
+	}
+	return time.Since(start), nil
+}
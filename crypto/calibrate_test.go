@@ -0,0 +1,45 @@
+package crypto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalibrateKDF(t *testing.T) {
+	target := 20 * time.Millisecond
+	maxMemory := uint64(16 * 1024 * 1024)
+
+	for _, kdfType := range []KDFType{ARGON2ID, SCRYPT, PBKDF2} {
+		kdf, err := CalibrateKDF(kdfType, target, maxMemory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, kdfType, kdf.GetType(), "kdf type mismatch")
+
+		switch k := kdf.(type) {
+		case *Argon2idKDF:
+			assert.True(t, uint64(k.Memory)*1024 <= maxMemory, "argon2id memory over budget")
+			assert.True(t, k.Time >= 1, "argon2id needs at least one pass")
+		case *ScryptKDF:
+			assert.True(t, scryptMemory(k) <= maxMemory, "scrypt memory over budget")
+		case *PBKDF2KDF:
+			assert.True(t, k.Iterations >= minPBKDF2Iterations, "pbkdf2 iterations too low")
+		}
+
+		_, err = kdf.DeriveKey([]byte("geronimo"), []byte("saltsaltsalt"), keySize)
+		assert.Nil(t, err, "calibrated kdf should derive a key")
+	}
+}
+
+func TestCalibrateKDFInvalid(t *testing.T) {
+	_, err := CalibrateKDF(ARGON2ID, 0, 16*1024*1024)
+	assert.Error(t, err, "zero target should not calibrate")
+
+	_, err = CalibrateKDF(ARGON2ID, time.Second, 1024)
+	assert.Error(t, err, "tiny memory budget should not calibrate")
+
+	_, err = CalibrateKDF(UnknownKDF, time.Second, 16*1024*1024)
+	assert.Error(t, err, "unknown kdf should not calibrate")
+}
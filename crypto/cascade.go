@@ -0,0 +1,174 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	aesTwofishName        = "AES-TWOFISH"
+	aesTwofishSerpentName = "AES-TWOFISH-SERPENT"
+	serpentAESName        = "SERPENT-AES"
+	serpentTwofishAESName = "SERPENT-TWOFISH-AES"
+	twofishSerpentName    = "TWOFISH-SERPENT"
+)
+
+// CascadeCipher encrypts with several ciphers in turn, each keyed with its own
+// subkey, so the data stays safe as long as one of them holds. As in VeraCrypt
+// the name lists the outermost cipher first, AES-TWOFISH-SERPENT encrypts with
+// Serpent first and AES last.
+type CascadeCipher struct {
+	description string
+	name        string
+	cipherType  CipherType
+	layers      []CipherType
+}
+
+// NewAESTwofishCipher constructor
+func NewAESTwofishCipher() *CascadeCipher {
+	return newCascadeCipher(AESTWOFISH, aesTwofishName, "AES-Twofish-GCM cascade cipher", AES256, TWOFISH)
+}
+
+// NewAESTwofishSerpentCipher constructor
+func NewAESTwofishSerpentCipher() *CascadeCipher {
+	return newCascadeCipher(AESTWOFISHSERPENT, aesTwofishSerpentName, "AES-Twofish-Serpent-GCM cascade cipher", AES256, TWOFISH, SERPENT)
+}
+
+// NewSerpentAESCipher constructor
+func NewSerpentAESCipher() *CascadeCipher {
+	return newCascadeCipher(SERPENTAES, serpentAESName, "Serpent-AES-GCM cascade cipher", SERPENT, AES256)
+}
+
+// NewSerpentTwofishAESCipher constructor
+func NewSerpentTwofishAESCipher() *CascadeCipher {
+	return newCascadeCipher(SERPENTTWOFISHAES, serpentTwofishAESName, "Serpent-Twofish-AES-GCM cascade cipher", SERPENT, TWOFISH, AES256)
+}
+
+// NewTwofishSerpentCipher constructor
+func NewTwofishSerpentCipher() *CascadeCipher {
+	return newCascadeCipher(TWOFISHSERPENT, twofishSerpentName, "Twofish-Serpent-GCM cascade cipher", TWOFISH, SERPENT)
+}
+
+// newCascadeCipher creates a cascade of layers, given outermost first
+func newCascadeCipher(cipherType CipherType, name string, description string, layers ...CipherType) *CascadeCipher {
+	r := &CascadeCipher{}
+	r.description = description
+	r.name = name
+	r.cipherType = cipherType
+	r.layers = layers
+
+	return r
+}
+
+// GetDescription returns description string
+func (c *CascadeCipher) GetDescription() string {
+	return c.description
+}
+
+// GetName returns name string
+func (c *CascadeCipher) GetName() string {
+	return c.name
+}
+
+// GetType returns CryptType
+func (c *CascadeCipher) GetType() CipherType {
+	return c.cipherType
+}
+
+// GetInfo returns the layers of the cascade, outermost first, and their mode.
+// The cascade is as slow as its layers together.
+func (c *CascadeCipher) GetInfo() CipherInfo {
+	info := CipherInfo{KeySize: keySize, Mode: "GCM", AEAD: true, Speed: SpeedSlow}
+	for _, layerType := range c.layers {
+		layer, err := getCipher(layerType)
+		if err != nil {
+			continue
+		}
+		info.Layers = append(info.Layers, layer.GetName())
+		if described, ok := layer.(describedCipher); ok {
+			layerInfo := described.GetInfo()
+			if layerInfo.BlockSize > info.BlockSize {
+				info.BlockSize = layerInfo.BlockSize
+			}
+		}
+	}
+	return info
+}
+
+// NewAEAD returns the cascade keyed with key. Every layer gets a subkey
+// derived from key with HKDF-SHA-256, bound to the cascade and layer names.
+func (c *CascadeCipher) NewAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, errors.New("creating aead cipher: invalid key size")
+	}
+
+	cascade := &cascadeAEAD{}
+	for _, layerType := range c.layers {
+		layer, err := getCipher(layerType)
+		if err != nil {
+			return nil, errors.Wrapf(err, "creating cascade layer")
+		}
+
+		kdf := hkdf.New(sha256.New, key, nil, []byte("krypt "+c.name+" "+layer.GetName()))
+		subKey := make([]byte, keySize)
+		if _, err := io.ReadFull(kdf, subKey); err != nil {
+			return nil, errors.Wrapf(err, "deriving subkeys")
+		}
+
+		modeCipher, err := layer.NewAEAD(subKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(cascade.layers) > 0 && modeCipher.NonceSize() != cascade.NonceSize() {
+			return nil, errors.New("creating aead cipher: cascade nonce sizes differ")
+		}
+		cascade.layers = append(cascade.layers, modeCipher)
+	}
+	if len(cascade.layers) == 0 {
+		return nil, errors.New("creating aead cipher: empty cascade")
+	}
+	return cascade, nil
+}
+
+// cascadeAEAD seals with each layer in turn, innermost first, so the output
+// takes the form ciphertext|innerTag|...|outerTag. All layers share the nonce,
+// which is safe since their keys are independent, and the additional data.
+type cascadeAEAD struct {
+	layers []cipher.AEAD
+}
+
+func (a *cascadeAEAD) NonceSize() int {
+	return a.layers[0].NonceSize()
+}
+
+func (a *cascadeAEAD) Overhead() int {
+	overhead := 0
+	for _, layer := range a.layers {
+		overhead += layer.Overhead()
+	}
+	return overhead
+}
+
+func (a *cascadeAEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	sealed := plaintext
+	for i := len(a.layers) - 1; i >= 0; i-- {
+		sealed = a.layers[i].Seal(nil, nonce, sealed, additionalData)
+	}
+	return append(dst, sealed...)
+}
+
+func (a *cascadeAEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	opened := ciphertext
+	for _, layer := range a.layers {
+		var err error
+		if opened, err = layer.Open(nil, nonce, opened, additionalData); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, opened...), nil
+}
@@ -0,0 +1,85 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCascadeCipher(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	pass := []byte("geronimo")
+
+	for _, cipherType := range []CipherType{AESTWOFISH, AESTWOFISHSERPENT, SERPENTAES,
+		SERPENTTWOFISHAES, TWOFISHSERPENT} {
+		cipher, err := getCipher(cipherType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encryptedData, err := EncryptWithOptions(cipherType, pass, data, Options{KDF: NewPBKDF2KDF(1000)})
+		if err != nil {
+			t.Fatalf("%s: %v", cipher.GetName(), err)
+		}
+		decryptedData, err := Decrypt(pass, encryptedData)
+		if err != nil {
+			t.Fatalf("%s: %v", cipher.GetName(), err)
+		}
+		assert.Equal(t, data, decryptedData, "%s: decrypted data does not match", cipher.GetName())
+	}
+}
+
+func TestCascadeLayers(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, keySize)
+	nonce := make([]byte, 12)
+	data := []byte("This is the test data to compare")
+
+	cascade, err := NewAESTwofishSerpentCipher().NewAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := cascade.Seal(nil, nonce, data, nil)
+	assert.Equal(t, len(data)+3*16, len(sealed), "every layer should add a tag")
+
+	// the layers are not keyed with the cascade key itself
+	aes, err := NewAES256Cipher().NewAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = aes.Open(nil, nonce, sealed, nil)
+	assert.Error(t, err, "outer layer should use its own subkey")
+
+	// a cascade with the same layers in another order is a different cipher
+	reversed, err := NewSerpentTwofishAESCipher().NewAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = reversed.Open(nil, nonce, sealed, nil)
+	assert.Error(t, err, "cascade order should matter")
+}
+
+func TestCascadeTampered(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, keySize)
+	nonce := make([]byte, 12)
+	data := []byte("This is the test data to compare")
+
+	cascade, err := NewTwofishSerpentCipher().NewAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := cascade.Seal(nil, nonce, data, []byte("ad"))
+
+	opened, err := cascade.Open(nil, nonce, sealed, []byte("ad"))
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, data, opened, "opened data does not match")
+
+	for i := range sealed {
+		tampered := append([]byte{}, sealed...)
+		tampered[i] ^= 0x01
+		_, err = cascade.Open(nil, nonce, tampered, []byte("ad"))
+		assert.Error(t, err, "byte %d altered should not open", i)
+	}
+	_, err = cascade.Open(nil, nonce, sealed, []byte("other"))
+	assert.Error(t, err, "other additional data should not open")
+}
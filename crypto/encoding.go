@@ -0,0 +1,181 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+// Encoding is the form sealed data is stored in
+type Encoding int
+
+// encodings of sealed data
+const (
+	EncodingNone Encoding = iota // not sealed data
+	EncodingBinary
+	EncodingBase64
+	EncodingArmor
+	EncodingBase64URL // unpadded
+	EncodingBase32
+	EncodingHex
+)
+
+var encodingNames = map[Encoding]string{
+	EncodingBinary:    "binary",
+	EncodingBase64:    "base64",
+	EncodingArmor:     "armor",
+	EncodingBase64URL: "base64url",
+	EncodingBase32:    "base32",
+	EncodingHex:       "hex",
+}
+
+// String returns the name of the encoding
+func (e Encoding) String() string {
+	if name, ok := encodingNames[e]; ok {
+		return name
+	}
+	return "none"
+}
+
+// IsText returns whether the encoding stores sealed data as text
+func (e Encoding) IsText() bool {
+	return e != EncodingNone && e != EncodingBinary
+}
+
+// Encodings returns the encodings sealed data can be written in
+func Encodings() []Encoding {
+	return []Encoding{EncodingBinary, EncodingBase64, EncodingBase64URL, EncodingBase32, EncodingHex, EncodingArmor}
+}
+
+// ParseEncoding returns the encoding with the given name, without regard to
+// letter case or surrounding whitespace
+func ParseEncoding(name string) (Encoding, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, encoding := range Encodings() {
+		if encoding.String() == name {
+			return encoding, nil
+		}
+	}
+	return EncodingNone, NewUnknownEncodingError()
+}
+
+// NewEncoder returns a writer that encodes everything written to it and
+// writes the result to w. Close must be called to flush the encoding; it does
+// not close w. Text is written without line breaks, except for armor.
+func NewEncoder(w io.Writer, encoding Encoding) (io.WriteCloser, error) {
+	switch encoding {
+	case EncodingBinary:
+		return nopWriteCloser{w}, nil
+	case EncodingBase64:
+		return base64.NewEncoder(base64.StdEncoding, w), nil
+	case EncodingBase64URL:
+		return base64.NewEncoder(base64.RawURLEncoding, w), nil
+	case EncodingBase32:
+		return base32.NewEncoder(base32.StdEncoding, w), nil
+	case EncodingHex:
+		return nopWriteCloser{hex.NewEncoder(w)}, nil
+	case EncodingArmor:
+		return NewArmorWriter(w, nil)
+	}
+	return nil, NewUnknownEncodingError()
+}
+
+// NewDecoder returns a reader of the data encoded in r. Whitespace in text
+// encodings is skipped, and base32 and hex are read in either letter case.
+func NewDecoder(r io.Reader, encoding Encoding) (io.Reader, error) {
+	switch encoding {
+	case EncodingBinary:
+		return r, nil
+	case EncodingBase64:
+		return base64.NewDecoder(base64.StdEncoding, &textReader{reader: r}), nil
+	case EncodingBase64URL:
+		return base64.NewDecoder(base64.RawURLEncoding, &textReader{reader: r, unpad: true}), nil
+	case EncodingBase32:
+		return base32.NewDecoder(base32.StdEncoding, &textReader{reader: r, upper: true}), nil
+	case EncodingHex:
+		return hex.NewDecoder(&textReader{reader: r}), nil
+	case EncodingArmor:
+		reader, _, err := NewArmorReader(r)
+		return reader, err
+	}
+	return nil, NewUnknownEncodingError()
+}
+
+// decodeStart decodes the whole quanta at the start of encoded text, to
+// recognize the sealed data in it. Whitespace is skipped as the decoders do.
+func decodeStart(text []byte, encoding Encoding) []byte {
+	var decoded []byte
+	var err error
+	text = bytes.Map(func(r rune) rune {
+		if r < 0x80 && isTextSpace(byte(r)) {
+			return -1
+		}
+		return r
+	}, text)
+	switch encoding {
+	case EncodingBase64, EncodingBase64URL:
+		// the start of either alphabet is decoded, the two are told apart by
+		// their own characters
+		text = bytes.Map(func(r rune) rune {
+			switch r {
+			case '-':
+				return '+'
+			case '_':
+				return '/'
+			}
+			return r
+		}, text)
+		decoded, err = base64.StdEncoding.DecodeString(string(text[:len(text)/4*4]))
+	case EncodingBase32:
+		decoded, err = base32.StdEncoding.DecodeString(strings.ToUpper(string(text[:len(text)/8*8])))
+	case EncodingHex:
+		decoded, err = hex.DecodeString(string(text[:len(text)/2*2]))
+	}
+	if err != nil {
+		return nil
+	}
+	return decoded
+}
+
+// textReader filters encoded text for the decoders
+type textReader struct {
+	reader io.Reader
+	upper  bool // change letters to upper case
+	unpad  bool // drop '=' padding
+}
+
+func (t *textReader) Read(p []byte) (int, error) {
+	for {
+		n, err := t.reader.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			switch {
+			case isTextSpace(b):
+				continue
+			case t.unpad && b == '=':
+				continue
+			case t.upper && 'a' <= b && b <= 'z':
+				b -= 'a' - 'A'
+			}
+			p[kept] = b
+			kept++
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// isTextSpace returns whether b is whitespace the decoders skip
+func isTextSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
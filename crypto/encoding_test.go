@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeData(t *testing.T, data []byte, encoding Encoding) []byte {
+	buffer := new(bytes.Buffer)
+	encoder, err := NewEncoder(buffer, encoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encoder.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestEncodings(t *testing.T) {
+	pass := []byte("geronimo")
+	// long enough that base64url text is sure to hold its own characters
+	data := bytes.Repeat([]byte("This is the test data to compare"), 40)
+	sealed, err := EncryptWithOptions(AES256, pass, data, Options{KDF: NewPBKDF2KDF(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, encoding := range Encodings() {
+		encoded := encodeData(t, sealed, encoding)
+		assert.Equal(t, encoding, Inspect(encoded), "%s: inspect mismatch", encoding)
+		assert.Equal(t, encoding.String() != "binary", encoding.IsText(), "%s: text mismatch", encoding)
+
+		parsed, err := ParseEncoding(" " + strings.ToUpper(encoding.String()) + " ")
+		assert.NoError(t, err, "%s: parse failed", encoding)
+		assert.Equal(t, encoding, parsed, "%s: parse mismatch", encoding)
+
+		decoder, err := NewDecoder(bytes.NewReader(encoded), encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plainText, err := NewDecryptReader(decoder, pass)
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		decrypted, err := ioutil.ReadAll(plainText)
+		assert.NoError(t, err, "%s: decrypt failed", encoding)
+		assert.Equal(t, data, decrypted, "%s: data mismatch", encoding)
+	}
+
+	_, err = ParseEncoding("rot13")
+	assert.True(t, errors.As(err, new(*UnknownEncodingError)), "unknown name: got %v", err)
+	_, err = NewEncoder(new(bytes.Buffer), EncodingNone)
+	assert.Error(t, err, "encoding none should fail")
+}
+
+func TestDecoderText(t *testing.T) {
+	data := bytes.Repeat([]byte("\xfb\xff\xfe decode me"), 20)
+	tests := []struct {
+		encoding Encoding
+		alter    func(string) string
+	}{
+		{EncodingBase64, wrapText},
+		{EncodingBase64URL, wrapText},
+		{EncodingBase64URL, func(s string) string { return s + "==" }},
+		{EncodingBase32, strings.ToLower},
+		{EncodingBase32, wrapText},
+		{EncodingHex, strings.ToUpper},
+		{EncodingHex, wrapText},
+	}
+	for _, test := range tests {
+		text := test.alter(string(encodeData(t, data, test.encoding)))
+		decoder, err := NewDecoder(strings.NewReader(text), test.encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := ioutil.ReadAll(decoder)
+		assert.NoError(t, err, "%s: decode failed", test.encoding)
+		assert.Equal(t, data, decoded, "%s: data mismatch", test.encoding)
+	}
+}
+
+// wrapText breaks text into lines of 20 with CRLF line endings
+func wrapText(text string) string {
+	lines := []string{}
+	for len(text) > 20 {
+		lines = append(lines, text[:20])
+		text = text[20:]
+	}
+	return "  " + strings.Join(append(lines, text), "\r\n") + "\r\n"
+}
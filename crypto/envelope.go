@@ -0,0 +1,500 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// flagEnvelope marks a header that is followed by key stanzas. The payload of
+// an envelope container is sealed under a random data key instead of a key
+// derived from a password, and each stanza holds the data key wrapped for
+// one recipient.
+const flagEnvelope = uint16(1 << 0)
+
+// limits on stanzas, used to reject garbage before allocating for it
+const (
+	maxStanzas         = 255
+	maxStanzaFields    = 16
+	maxStanzaFieldSize = 4096
+)
+
+const headerMACSize = sha256.Size
+
+// StanzaType identifies the kind of recipient a stanza was wrapped for
+type StanzaType uint8
+
+// stanza types
+const (
+	UnknownStanza StanzaType = iota
+	X25519Stanza
+	SSHEd25519Stanza
+	SSHRSAStanza
+	SSHAgentStanza
+	PasswordStanza
+	SplitStanza
+)
+
+// String returns the name of the stanza type
+func (t StanzaType) String() string {
+	switch t {
+	case X25519Stanza:
+		return "x25519"
+	case SSHEd25519Stanza:
+		return "ssh-ed25519"
+	case SSHRSAStanza:
+		return "ssh-rsa"
+	case SSHAgentStanza:
+		return "ssh-agent"
+	case PasswordStanza:
+		return "password"
+	case SplitStanza:
+		return "split"
+	}
+	return "unknown"
+}
+
+// Stanza holds the data key of an envelope wrapped for a single recipient.
+// The meaning of the fields depends on the stanza type.
+type Stanza struct {
+	Type   StanzaType
+	Fields [][]byte
+}
+
+// Recipient wraps the data key of an envelope so that only the matching
+// Identity can unwrap it
+type Recipient interface {
+	Wrap(dataKey []byte) (*Stanza, error)
+}
+
+// Identity unwraps the data key from the stanzas meant for it. Unwrap returns
+// a nil key, and no error, when the stanza is not meant for the identity.
+type Identity interface {
+	Unwrap(stanza *Stanza) ([]byte, error)
+}
+
+// Envelope is the key material of an envelope container, the data key and
+// the stanzas wrapping it. Sealing with an existing envelope keeps every
+// recipient that could open the original.
+type Envelope struct {
+	dataKey []byte
+	stanzas []*Stanza
+	opened  int
+}
+
+// NewEnvelope creates an envelope with a random data key wrapped for each
+// of the recipients
+func NewEnvelope(recipients []Recipient) (*Envelope, error) {
+	e := &Envelope{dataKey: make([]byte, keySize), opened: -1}
+	if _, err := io.ReadFull(rand.Reader, e.dataKey); err != nil {
+		return nil, errors.Wrapf(err, "randomizing data key")
+	}
+	for _, recipient := range recipients {
+		if err := e.AddRecipient(recipient); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// AddRecipient wraps the data key for one more recipient
+func (e *Envelope) AddRecipient(recipient Recipient) error {
+	if len(e.stanzas) >= maxStanzas {
+		return errors.New("too many recipients")
+	}
+	stanza, err := recipient.Wrap(e.dataKey)
+	if err != nil {
+		return errors.Wrap(err, "wrapping data key")
+	}
+	e.stanzas = append(e.stanzas, stanza)
+	return nil
+}
+
+// Stanzas returns the stanzas of the envelope
+func (e *Envelope) Stanzas() []*Stanza {
+	return append([]*Stanza{}, e.stanzas...)
+}
+
+// Opened returns the index of the stanza the envelope was opened with, or -1
+// for a new envelope or once that stanza is removed
+func (e *Envelope) Opened() int {
+	return e.opened
+}
+
+// RemoveStanza removes the stanza at index i, the last stanza cannot be
+// removed since nothing could open the data without it
+func (e *Envelope) RemoveStanza(i int) error {
+	if i < 0 || i >= len(e.stanzas) {
+		return errors.Errorf("no stanza %d", i)
+	}
+	if len(e.stanzas) == 1 {
+		return errors.New("the last stanza cannot be removed")
+	}
+	e.stanzas = append(append([]*Stanza{}, e.stanzas[:i]...), e.stanzas[i+1:]...)
+	if i == e.opened {
+		e.opened = -1
+	} else if i < e.opened {
+		e.opened--
+	}
+	return nil
+}
+
+// ReadStanzas reads the stanzas of sealed data without opening it. Version 1
+// data, from before key slots, has none.
+func ReadStanzas(reader io.Reader) ([]*Stanza, error) {
+	h, err := readHeader(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading krypt")
+	}
+	if h.version == legacyVersion {
+		return []*Stanza{}, nil
+	}
+	stanzas, err := readStanzas(reader)
+	if err != nil {
+		return nil, readError(err, "reading krypt stanzas")
+	}
+	return stanzas, nil
+}
+
+// RewrapEnvelope copies the sealed data read from reader to writer with its
+// stanzas replaced by those of the envelope, which must hold the data key
+// the data was sealed under. The payload is copied as it is, without being
+// opened.
+func RewrapEnvelope(writer io.Writer, reader io.Reader, e *Envelope) error {
+	if len(e.stanzas) == 0 {
+		return errors.New("no recipients")
+	}
+
+	headerData := new(bytes.Buffer)
+	h, err := readHeader(io.TeeReader(reader, headerData))
+	if err != nil {
+		return errors.Wrap(err, "reading krypt")
+	}
+	if h.version == legacyVersion {
+		return errors.New("data has no stanzas to rewrap")
+	}
+	stanzaData := new(bytes.Buffer)
+	if _, err := readStanzas(io.TeeReader(reader, stanzaData)); err != nil {
+		return readError(err, "reading krypt stanzas")
+	}
+	mac := make([]byte, headerMACSize)
+	if _, err := io.ReadFull(reader, mac); err != nil {
+		return readError(err, "reading krypt header mac")
+	}
+	expected, err := e.headerMAC(h, headerData.Bytes(), stanzaData.Bytes())
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, expected) {
+		return errors.New("envelope does not hold the data key")
+	}
+
+	newStanzaData, err := e.marshalStanzas(h, headerData.Bytes())
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(headerData.Bytes()); err != nil {
+		return errors.Wrap(err, "writing krypt info")
+	}
+	if _, err := writer.Write(newStanzaData); err != nil {
+		return errors.Wrap(err, "writing krypt stanzas")
+	}
+	if _, err := io.Copy(writer, reader); err != nil {
+		return errors.Wrap(err, "copying payload")
+	}
+	return nil
+}
+
+// sealEnvelope returns the envelope to seal with, if the options ask for one
+func sealEnvelope(opts Options) (*Envelope, error) {
+	if opts.Envelope == nil && len(opts.Recipients) == 0 {
+		return nil, nil
+	}
+
+	e := opts.Envelope
+	if e == nil {
+		var err error
+		if e, err = NewEnvelope(nil); err != nil {
+			return nil, err
+		}
+	} else {
+		e = &Envelope{dataKey: e.dataKey, stanzas: e.Stanzas(), opened: -1}
+	}
+	for _, recipient := range opts.Recipients {
+		if err := e.AddRecipient(recipient); err != nil {
+			return nil, err
+		}
+	}
+	if len(e.stanzas) == 0 {
+		return nil, errors.New("no recipients")
+	}
+	return e, nil
+}
+
+// openEnvelope reads the stanzas and header mac following the header, and
+// unwraps the data key with the first identity that matches a stanza. The
+// password is tried last, and asked for with the prompt of the options when
+// none was given and nothing else opens a password stanza. Password stanzas
+// that need a keyfile are only tried when the options hold one.
+func openEnvelope(reader io.Reader, h *header, headerData []byte, password []byte, opts Options) (*Envelope, error) {
+	stanzaData := new(bytes.Buffer)
+	stanzas, err := readStanzas(io.TeeReader(reader, stanzaData))
+	if err != nil {
+		return nil, readError(err, "reading krypt stanzas")
+	}
+	mac := make([]byte, headerMACSize)
+	if _, err := io.ReadFull(reader, mac); err != nil {
+		return nil, readError(err, "reading krypt header mac")
+	}
+
+	identities := opts.Identities
+	if len(password) > 0 {
+		identities = append(append([]Identity{}, identities...), NewPasswordIdentityWithKeyfile(password, opts.Keyfile))
+	}
+	dataKey, opened, unwrapErr := unwrapStanzas(stanzas, identities)
+	if dataKey == nil && len(opts.Keyfile) == 0 && needsKeyfile(stanzas) {
+		return nil, NewKeyfileRequiredError()
+	}
+	if dataKey == nil && len(password) == 0 && opts.PasswordPrompt != nil && hasStanzaType(stanzas, PasswordStanza) {
+		if password, err = promptPassword(password, opts); err != nil {
+			return nil, err
+		}
+		if dataKey, opened, err = unwrapStanzas(stanzas, []Identity{NewPasswordIdentityWithKeyfile(password, opts.Keyfile)}); err != nil {
+			return nil, err
+		}
+	}
+	if dataKey == nil && unwrapErr != nil {
+		return nil, unwrapErr
+	}
+	if dataKey == nil {
+		return nil, NewNoIdentityMatchError()
+	}
+
+	e := &Envelope{dataKey: dataKey, stanzas: stanzas, opened: opened}
+	expected, err := e.headerMAC(h, headerData, stanzaData.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, expected) {
+		return nil, &TamperedError{"krypt header has been altered"}
+	}
+	return e, nil
+}
+
+// unwrapStanzas returns the data key unwrapped by the first identity that
+// matches a stanza and the index of that stanza, or nil when none does. An
+// identity that fails to unwrap a stanza does not stop the others from being
+// tried, its error is only returned when no stanza is unwrapped.
+func unwrapStanzas(stanzas []*Stanza, identities []Identity) ([]byte, int, error) {
+	var unwrapErr error
+	for i, stanza := range stanzas {
+		for _, identity := range identities {
+			dataKey, err := identity.Unwrap(stanza)
+			if err != nil {
+				if unwrapErr == nil {
+					unwrapErr = errors.Wrap(err, "unwrapping data key")
+				}
+				continue
+			}
+			if dataKey != nil {
+				return dataKey, i, nil
+			}
+		}
+	}
+	return nil, -1, unwrapErr
+}
+
+// needsKeyfile returns whether every password stanza needs a keyfile, so a
+// password alone opens none of them
+func needsKeyfile(stanzas []*Stanza) bool {
+	if !hasStanzaType(stanzas, PasswordStanza) {
+		return false
+	}
+	for _, stanza := range stanzas {
+		if stanza.Type == PasswordStanza && !PasswordStanzaKeyfile(stanza) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasStanzaType(stanzas []*Stanza, stanzaType StanzaType) bool {
+	for _, stanza := range stanzas {
+		if stanza.Type == stanzaType {
+			return true
+		}
+	}
+	return false
+}
+
+// marshalStanzas returns the stanzas followed by the header mac, as they are
+// written after the header
+func (e *Envelope) marshalStanzas(h *header, headerData []byte) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	buffer.WriteByte(byte(len(e.stanzas)))
+	for _, stanza := range e.stanzas {
+		if len(stanza.Fields) > maxStanzaFields {
+			return nil, errors.New("too many stanza fields")
+		}
+		buffer.WriteByte(byte(stanza.Type))
+		buffer.WriteByte(byte(len(stanza.Fields)))
+		for _, field := range stanza.Fields {
+			if len(field) > maxStanzaFieldSize {
+				return nil, errors.New("stanza field too long")
+			}
+			binary.Write(buffer, binary.LittleEndian, uint16(len(field)))
+			buffer.Write(field)
+		}
+	}
+
+	mac, err := e.headerMAC(h, headerData, buffer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	buffer.Write(mac)
+	return buffer.Bytes(), nil
+}
+
+// readStanzas reads the stanzas that follow an envelope header
+func readStanzas(reader io.Reader) ([]*Stanza, error) {
+	var count uint8
+	if err := binary.Read(reader, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errors.New("no stanzas")
+	}
+
+	stanzas := make([]*Stanza, count)
+	for i := range stanzas {
+		var info [2]uint8
+		if _, err := io.ReadFull(reader, info[:]); err != nil {
+			return nil, err
+		}
+		if info[1] > maxStanzaFields {
+			return nil, errors.New("too many stanza fields")
+		}
+
+		stanza := &Stanza{Type: StanzaType(info[0]), Fields: make([][]byte, info[1])}
+		for j := range stanza.Fields {
+			var fieldLen uint16
+			if err := binary.Read(reader, binary.LittleEndian, &fieldLen); err != nil {
+				return nil, err
+			}
+			if fieldLen > maxStanzaFieldSize {
+				return nil, errors.New("stanza field too long")
+			}
+			stanza.Fields[j] = make([]byte, fieldLen)
+			if _, err := io.ReadFull(reader, stanza.Fields[j]); err != nil {
+				return nil, err
+			}
+		}
+		stanzas[i] = stanza
+	}
+	return stanzas, nil
+}
+
+// payloadKey derives the key the payload is sealed with from the data key
+func (e *Envelope) payloadKey(h *header) ([]byte, error) {
+	return e.deriveKey(h, "krypt payload")
+}
+
+// headerMAC authenticates the header and stanzas with a key derived from the
+// data key. The payload is only bound to the header itself, so the stanzas
+// can be changed without sealing the payload again.
+func (e *Envelope) headerMAC(h *header, headerData []byte, stanzaData []byte) ([]byte, error) {
+	macKey, err := e.deriveKey(h, "krypt header")
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(headerData)
+	mac.Write(stanzaData)
+	return mac.Sum(nil), nil
+}
+
+func (e *Envelope) deriveKey(h *header, info string) ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, e.dataKey, h.salt, []byte(info)), key); err != nil {
+		return nil, errors.Wrapf(err, "deriving key")
+	}
+	return key, nil
+}
+
+// ParseRecipient parses a public key in its text form
+func ParseRecipient(s string) (Recipient, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(strings.ToLower(s), x25519PublicPrefix):
+		return ParseX25519Recipient(s)
+	default:
+		return nil, errors.New("unknown recipient type")
+	}
+}
+
+// ParseRecipients reads public keys in their text form, one per line. Empty
+// lines and lines starting with '#' are skipped.
+func ParseRecipients(reader io.Reader) ([]Recipient, error) {
+	recipients := []Recipient{}
+	err := parseKeyLines(reader, func(line string) error {
+		recipient, err := ParseRecipient(line)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, recipient)
+		return nil
+	})
+	return recipients, err
+}
+
+// ParseIdentity parses a private key in its text form
+func ParseIdentity(s string) (Identity, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(strings.ToUpper(s), x25519SecretPrefix):
+		return ParseX25519Identity(s)
+	default:
+		return nil, errors.New("unknown identity type")
+	}
+}
+
+// ParseIdentities reads private keys in their text form, one per line. Empty
+// lines and lines starting with '#' are skipped.
+func ParseIdentities(reader io.Reader) ([]Identity, error) {
+	identities := []Identity{}
+	err := parseKeyLines(reader, func(line string) error {
+		identity, err := ParseIdentity(line)
+		if err != nil {
+			return err
+		}
+		identities = append(identities, identity)
+		return nil
+	})
+	return identities, err
+}
+
+// parseKeyLines calls parse for each line holding a key
+func parseKeyLines(reader io.Reader, parse func(line string) error) error {
+	scanner := bufio.NewScanner(reader)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := parse(line); err != nil {
+			return errors.Wrapf(err, "line %d", lineNum)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,256 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestIdentities(t *testing.T, count int) []*X25519Identity {
+	identities := []*X25519Identity{}
+	for i := 0; i < count; i++ {
+		identity, err := GenerateX25519Identity()
+		if err != nil {
+			t.Fatal(err)
+		}
+		identities = append(identities, identity)
+	}
+	return identities
+}
+
+func TestEnvelopeCrypt(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	identities := newTestIdentities(t, 3)
+	recipients := []Recipient{identities[0].Recipient(), identities[1].Recipient()}
+
+	encryptedData, err := EncryptWithOptions(AES256, nil, data, Options{Recipients: recipients})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := readHeader(bytes.NewReader(encryptedData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, flagEnvelope, h.flags&flagEnvelope, "envelope flag not set")
+
+	for _, identity := range identities[:2] {
+		decryptedData, err := DecryptWithOptions(nil, encryptedData,
+			Options{Identities: []Identity{identities[2], identity}})
+		assert.Nil(t, err, "unexpected error")
+		assert.Equal(t, data, decryptedData, "decrypted data does not match")
+	}
+
+	_, err = DecryptWithOptions(nil, encryptedData, Options{Identities: []Identity{identities[2]}})
+	assert.IsType(t, &NoIdentityMatchError{}, err, "unexpected error")
+
+	_, err = Decrypt([]byte("geronimo"), encryptedData)
+	assert.IsType(t, &NoIdentityMatchError{}, err, "a password should not open an envelope")
+}
+
+// failingIdentity fails to unwrap every stanza, like an ssh-agent that has
+// gone away
+type failingIdentity struct{}
+
+func (failingIdentity) Unwrap(stanza *Stanza) ([]byte, error) {
+	return nil, errors.New("agent refused")
+}
+
+func TestEnvelopeFailingIdentity(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	identities := newTestIdentities(t, 1)
+	encryptedData, err := EncryptWithOptions(AES256, nil, data,
+		Options{Recipients: []Recipient{identities[0].Recipient()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decryptedData, err := DecryptWithOptions(nil, encryptedData,
+		Options{Identities: []Identity{failingIdentity{}, identities[0]}})
+	assert.Nil(t, err, "a failing identity should not stop the next one")
+	assert.Equal(t, data, decryptedData, "decrypted data does not match")
+
+	_, err = DecryptWithOptions(nil, encryptedData, Options{Identities: []Identity{failingIdentity{}}})
+	assert.EqualError(t, err, "unwrapping data key: agent refused", "unexpected error")
+}
+
+func TestEnvelopeWithPassword(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	identities := newTestIdentities(t, 1)
+	encryptedData, err := EncryptWithOptions(AES256, []byte("geronimo"), data,
+		Options{Recipients: []Recipient{identities[0].Recipient()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the password and the recipient each have a key slot
+	decryptedData, err := Decrypt([]byte("geronimo"), encryptedData)
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, data, decryptedData, "decrypted data does not match")
+	decryptedData, err = DecryptWithOptions(nil, encryptedData, Options{Identities: []Identity{identities[0]}})
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, data, decryptedData, "decrypted data does not match")
+
+	_, err = Decrypt([]byte("cowabunga"), encryptedData)
+	assert.IsType(t, &NoIdentityMatchError{}, err, "wrong password should not decrypt")
+}
+
+func TestEnvelopeRewrap(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	opts := Options{KDF: NewPBKDF2KDF(1000)}
+	encryptedData, err := EncryptWithOptions(SERPENT, []byte("geronimo"), data, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, envelope, err := NewDecryptReaderWithEnvelope(bytes.NewReader(encryptedData), []byte("geronimo"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, envelope.Opened(), "opened stanza mismatch")
+	if err := envelope.AddRecipient(NewPasswordRecipient([]byte("cowabunga"), opts.KDF)); err != nil {
+		t.Fatal(err)
+	}
+	rewrapped := new(bytes.Buffer)
+	if err := RewrapEnvelope(rewrapped, bytes.NewReader(encryptedData), envelope); err != nil {
+		t.Fatal(err)
+	}
+	stanzas, err := ReadStanzas(bytes.NewReader(rewrapped.Bytes()))
+	assert.Nil(t, err, "unexpected error")
+	assert.Len(t, stanzas, 2, "stanza count mismatch")
+
+	for _, password := range []string{"geronimo", "cowabunga"} {
+		decryptedData, err := Decrypt([]byte(password), rewrapped.Bytes())
+		assert.Nil(t, err, "%s: unexpected error", password)
+		assert.Equal(t, data, decryptedData, "%s: decrypted data does not match", password)
+	}
+	_, opened, err := NewDecryptReaderWithEnvelope(bytes.NewReader(rewrapped.Bytes()), []byte("cowabunga"), Options{})
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, 1, opened.Opened(), "opened stanza mismatch")
+
+	// removing the first slot leaves only the new password
+	assert.Nil(t, envelope.RemoveStanza(0), "unexpected error")
+	assert.Equal(t, -1, envelope.Opened(), "removed stanza should not be the opened one")
+	assert.Error(t, envelope.RemoveStanza(0), "the last slot should not be removed")
+	assert.Error(t, envelope.RemoveStanza(5), "a missing slot should not be removed")
+	rewrapped.Reset()
+	if err := RewrapEnvelope(rewrapped, bytes.NewReader(encryptedData), envelope); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Decrypt([]byte("geronimo"), rewrapped.Bytes())
+	assert.IsType(t, &NoIdentityMatchError{}, err, "removed password should not decrypt")
+	decryptedData, err := Decrypt([]byte("cowabunga"), rewrapped.Bytes())
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, data, decryptedData, "decrypted data does not match")
+
+	// an envelope only rewraps the data it holds the key of
+	other, err := NewEnvelope([]Recipient{NewPasswordRecipient([]byte("geronimo"), opts.KDF)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RewrapEnvelope(new(bytes.Buffer), bytes.NewReader(encryptedData), other)
+	assert.Error(t, err, "other envelope should not rewrap")
+}
+
+func TestEnvelopeReseal(t *testing.T) {
+	identities := newTestIdentities(t, 3)
+	opts := Options{Recipients: []Recipient{identities[0].Recipient(), identities[1].Recipient()}}
+	encryptedData, err := EncryptWithOptions(AES256, nil, []byte("original data"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, envelope, err := NewDecryptReaderWithEnvelope(bytes.NewReader(encryptedData), nil,
+		Options{Identities: []Identity{identities[0]}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Read(make([]byte, 64)); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, envelope.Stanzas(), 2, "envelope should hold a stanza per recipient")
+
+	// sealing with the envelope keeps the original recipients
+	data := []byte("edited data")
+	encryptedData, err = EncryptWithOptions(TWOFISH, nil, data,
+		Options{Envelope: envelope, Recipients: []Recipient{identities[2].Recipient()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, envelope.Stanzas(), 2, "sealing should not change the given envelope")
+	for _, identity := range identities {
+		decryptedData, err := DecryptWithOptions(nil, encryptedData, Options{Identities: []Identity{identity}})
+		assert.Nil(t, err, "unexpected error")
+		assert.Equal(t, data, decryptedData, "decrypted data does not match")
+	}
+}
+
+func TestEnvelopeTampered(t *testing.T) {
+	identities := newTestIdentities(t, 2)
+	opts := Options{Recipients: []Recipient{identities[0].Recipient(), identities[1].Recipient()}}
+	encryptedData, err := EncryptWithOptions(AES256, nil, []byte("This is the test data to compare"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock := Options{Identities: []Identity{identities[1]}}
+
+	h, err := readHeader(bytes.NewReader(encryptedData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headerData, err := h.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// drop the first stanza, which the identity does not need
+	stanzas, err := readStanzas(bytes.NewReader(encryptedData[len(headerData):]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope := &Envelope{stanzas: stanzas[1:]}
+	stanzaData, err := envelope.marshalStanzas(h, headerData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stanzaLen := 1 + stanzaSize(stanzas[0]) + stanzaSize(stanzas[1]) + headerMACSize
+	tampered := append(append(append([]byte{}, headerData...), stanzaData...), encryptedData[len(headerData)+stanzaLen:]...)
+	_, err = DecryptWithOptions(nil, tampered, unlock)
+	assert.IsType(t, &TamperedError{}, err, "removed stanza should be detected")
+
+	for _, i := range []int{len(kryptMagic) + 1, len(headerData) + 3, len(headerData) + stanzaLen - 1} {
+		tampered := append([]byte{}, encryptedData...)
+		tampered[i] ^= 0x01
+		_, err = DecryptWithOptions(nil, tampered, unlock)
+		assert.Error(t, err, "byte %d altered should not decrypt", i)
+	}
+}
+
+// stanzaSize returns the size of the stanza as it is written after the header
+func stanzaSize(stanza *Stanza) int {
+	size := 2
+	for _, field := range stanza.Fields {
+		size += 2 + len(field)
+	}
+	return size
+}
+
+func TestParseKeys(t *testing.T) {
+	identities := newTestIdentities(t, 2)
+	text := "# krypt identities\n\n" + identities[0].String() + "\n  " + identities[1].String() + "  \n"
+
+	parsed, err := ParseIdentities(strings.NewReader(text))
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, []Identity{identities[0], identities[1]}, parsed, "identities mismatch")
+
+	text = identities[0].Recipient().String() + "\n# comment\n" + identities[1].Recipient().String()
+	recipients, err := ParseRecipients(strings.NewReader(text))
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, []Recipient{identities[0].Recipient(), identities[1].Recipient()}, recipients,
+		"recipients mismatch")
+
+	_, err = ParseRecipients(strings.NewReader("not a key"))
+	assert.EqualError(t, err, "line 1: unknown recipient type", "unexpected error")
+}
@@ -1,5 +1,17 @@
 package crypto
 
+// Sentinel errors to test for with errors.Is. They match any error of their
+// type, whatever its message, and errors.As finds the typed error itself.
+var (
+	ErrNotKrypt           error = NewDataIsNotEncryptedError()
+	ErrAlreadyKrypt       error = NewDataIsEcryptedError()
+	ErrWrongPassword      error = NewWrongPasswordError()
+	ErrKeyfileRequired    error = NewKeyfileRequiredError()
+	ErrTruncated          error = NewTruncatedError()
+	ErrTampered           error = NewTamperedError()
+	ErrUnsupportedVersion error = NewUnsupportedVersionError()
+)
+
 // DataIsEncryptedError when trying to encrypt already encrypted data
 type DataIsEncryptedError struct {
 	msg string // description of error
@@ -7,6 +19,12 @@ type DataIsEncryptedError struct {
 
 func (e *DataIsEncryptedError) Error() string { return e.msg }
 
+// Is matches any DataIsEncryptedError
+func (e *DataIsEncryptedError) Is(target error) bool {
+	_, ok := target.(*DataIsEncryptedError)
+	return ok
+}
+
 // NewDataIsEcryptedError returns a new error
 func NewDataIsEcryptedError() *DataIsEncryptedError {
 	return &DataIsEncryptedError{"data is already encrypted"}
@@ -19,6 +37,12 @@ type DataIsNotEncryptedError struct {
 
 func (e *DataIsNotEncryptedError) Error() string { return e.msg }
 
+// Is matches any DataIsNotEncryptedError
+func (e *DataIsNotEncryptedError) Is(target error) bool {
+	_, ok := target.(*DataIsNotEncryptedError)
+	return ok
+}
+
 // NewDataIsNotEncryptedError returns a new error
 func NewDataIsNotEncryptedError() *DataIsNotEncryptedError {
 	return &DataIsNotEncryptedError{"data is not encrypted"}
@@ -47,3 +71,167 @@ func (e *UnknownCipherTypeError) Error() string { return e.msg }
 func NewUnknownCipherTypeError() *UnknownCipherTypeError {
 	return &UnknownCipherTypeError{"cipher type not recognized"}
 }
+
+// UnknownKDFNameError when kdf name is not known
+type UnknownKDFNameError struct {
+	msg string // description of error
+}
+
+func (e *UnknownKDFNameError) Error() string { return e.msg }
+
+// NewUnknownKDFNameError returns a new error
+func NewUnknownKDFNameError() *UnknownKDFNameError {
+	return &UnknownKDFNameError{"kdf name not recognized"}
+}
+
+// UnknownKDFTypeError when kdf type is not known
+type UnknownKDFTypeError struct {
+	msg string // description of error
+}
+
+func (e *UnknownKDFTypeError) Error() string { return e.msg }
+
+// NewUnknownKDFTypeError returns a new error
+func NewUnknownKDFTypeError() *UnknownKDFTypeError {
+	return &UnknownKDFTypeError{"kdf type not recognized"}
+}
+
+// UnknownEncodingError when an encoding name or value is not known
+type UnknownEncodingError struct {
+	msg string // description of error
+}
+
+func (e *UnknownEncodingError) Error() string { return e.msg }
+
+// NewUnknownEncodingError returns a new error
+func NewUnknownEncodingError() *UnknownEncodingError {
+	return &UnknownEncodingError{"encoding not recognized"}
+}
+
+// LegacyCipherError when trying to encrypt with a legacy cipher
+type LegacyCipherError struct {
+	msg string // description of error
+}
+
+func (e *LegacyCipherError) Error() string { return e.msg }
+
+// NewLegacyCipherError returns a new error
+func NewLegacyCipherError() *LegacyCipherError {
+	return &LegacyCipherError{"legacy cipher can only be used to decrypt"}
+}
+
+// NoIdentityMatchError when none of the identities can open the data
+type NoIdentityMatchError struct {
+	msg string // description of error
+}
+
+func (e *NoIdentityMatchError) Error() string { return e.msg }
+
+// Is matches any NoIdentityMatchError, and ErrWrongPassword as the password or
+// keys given do not open the data
+func (e *NoIdentityMatchError) Is(target error) bool {
+	switch target.(type) {
+	case *NoIdentityMatchError, *WrongPasswordError:
+		return true
+	}
+	return false
+}
+
+// NewNoIdentityMatchError returns a new error
+func NewNoIdentityMatchError() *NoIdentityMatchError {
+	return &NoIdentityMatchError{"no identity matched any of the recipients"}
+}
+
+// KeyfileRequiredError when the password slots of the data also need a keyfile
+type KeyfileRequiredError struct {
+	msg string // description of error
+}
+
+func (e *KeyfileRequiredError) Error() string { return e.msg }
+
+// Is matches any KeyfileRequiredError
+func (e *KeyfileRequiredError) Is(target error) bool {
+	_, ok := target.(*KeyfileRequiredError)
+	return ok
+}
+
+// NewKeyfileRequiredError returns a new error
+func NewKeyfileRequiredError() *KeyfileRequiredError {
+	return &KeyfileRequiredError{"a keyfile is required along with the password"}
+}
+
+// WrongPasswordError when the password does not open the data. Version 1 and
+// openssl enc data cannot tell a wrong password from altered data, both are
+// reported as a wrong password.
+type WrongPasswordError struct {
+	msg string // description of error
+}
+
+func (e *WrongPasswordError) Error() string { return e.msg }
+
+// Is matches any WrongPasswordError
+func (e *WrongPasswordError) Is(target error) bool {
+	_, ok := target.(*WrongPasswordError)
+	return ok
+}
+
+// NewWrongPasswordError returns a new error
+func NewWrongPasswordError() *WrongPasswordError {
+	return &WrongPasswordError{"wrong password"}
+}
+
+// TruncatedError when the data ends before all of it has been read
+type TruncatedError struct {
+	msg string // description of error
+}
+
+func (e *TruncatedError) Error() string { return e.msg }
+
+// Is matches any TruncatedError
+func (e *TruncatedError) Is(target error) bool {
+	_, ok := target.(*TruncatedError)
+	return ok
+}
+
+// NewTruncatedError returns a new error
+func NewTruncatedError() *TruncatedError {
+	return &TruncatedError{"data is truncated"}
+}
+
+// TamperedError when the data, its header or key slots fail to authenticate.
+// Data cut short inside its final chunk cannot be told apart from an altered
+// final chunk, and is reported as tampered too.
+type TamperedError struct {
+	msg string // description of error
+}
+
+func (e *TamperedError) Error() string { return e.msg }
+
+// Is matches any TamperedError
+func (e *TamperedError) Is(target error) bool {
+	_, ok := target.(*TamperedError)
+	return ok
+}
+
+// NewTamperedError returns a new error
+func NewTamperedError() *TamperedError {
+	return &TamperedError{"data has been altered"}
+}
+
+// UnsupportedVersionError when the data was sealed by a newer version of krypt
+type UnsupportedVersionError struct {
+	msg string // description of error
+}
+
+func (e *UnsupportedVersionError) Error() string { return e.msg }
+
+// Is matches any UnsupportedVersionError
+func (e *UnsupportedVersionError) Is(target error) bool {
+	_, ok := target.(*UnsupportedVersionError)
+	return ok
+}
+
+// NewUnsupportedVersionError returns a new error
+func NewUnsupportedVersionError() *UnsupportedVersionError {
+	return &UnsupportedVersionError{"unsupported krypt version"}
+}
@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorsIs(t *testing.T) {
+	wrapped := pkgerrors.Wrap(&TamperedError{"krypt header has been altered"}, "reading krypt")
+	assert.True(t, errors.Is(wrapped, ErrTampered), "wrapped error should match its sentinel")
+	assert.False(t, errors.Is(wrapped, ErrTruncated), "error should not match other sentinels")
+
+	var tampered *TamperedError
+	assert.True(t, errors.As(wrapped, &tampered), "wrapped error should be found")
+	assert.Equal(t, "krypt header has been altered", tampered.Error(), "message mismatch")
+
+	assert.True(t, errors.Is(NewNoIdentityMatchError(), ErrWrongPassword), "no identity match should count as a wrong password")
+	assert.False(t, errors.Is(NewWrongPasswordError(), &NoIdentityMatchError{}), "wrong password should not match no identity")
+}
+
+func TestDecryptErrors(t *testing.T) {
+	pass := []byte("geronimo")
+	data := bytes.Repeat([]byte("x"), 2*defaultChunkSize+10)
+	sealed, err := EncryptWithOptions(AES256, pass, data, Options{KDF: NewPBKDF2KDF(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the final chunk holds the last 10 bytes
+	finalLen := 10 + 16
+	payloadLen := 2*(defaultChunkSize+16) + finalLen
+
+	tests := []struct {
+		name   string
+		data   []byte
+		target error
+	}{
+		{"empty", []byte{}, ErrNotKrypt},
+		{"plain text", []byte("completely random data"), ErrNotKrypt},
+		{"short magic", []byte("KRY"), ErrNotKrypt},
+		{"newer version", append([]byte("KRYPT"), 9, 1, 0), ErrUnsupportedVersion},
+		{"unknown flags", withByte(sealed, len(kryptMagic)+3, 0x80), ErrUnsupportedVersion},
+		{"cut in header", sealed[:len(kryptMagic)+4], ErrTruncated},
+		{"cut in stanzas", sealed[:len(sealed)-payloadLen-headerMACSize-3], ErrTruncated},
+		{"cut at chunk", sealed[:len(sealed)-finalLen], ErrTruncated},
+		{"altered mac", withByte(sealed, len(sealed)-payloadLen-1, 0x01), ErrTampered},
+		{"altered chunk", withByte(sealed, len(sealed)-1, 0x01), ErrTampered},
+	}
+	for _, test := range tests {
+		_, err := Decrypt(pass, test.data)
+		assert.True(t, errors.Is(err, test.target), "%s: expected %v, got %v", test.name, test.target, err)
+	}
+
+	_, err = Decrypt([]byte("cowabunga"), sealed)
+	assert.True(t, errors.Is(err, ErrWrongPassword), "wrong password: got %v", err)
+	var noMatch *NoIdentityMatchError
+	assert.True(t, errors.As(err, &noMatch), "wrong password should be a no identity match")
+}
+
+func TestLegacyDecryptErrors(t *testing.T) {
+	payload, err := NewSerpentCipher().Encrypt([]byte("This is the test data to compare"), []byte("geronimo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Decrypt([]byte("cowabunga"), mockKrypt(legacyVersion, SERPENT, payload))
+	assert.True(t, errors.Is(err, ErrWrongPassword), "wrong password: got %v", err)
+	_, err = Decrypt([]byte("geronimo"), mockKrypt(legacyVersion, SERPENT, nil))
+	assert.True(t, errors.Is(err, ErrTruncated), "missing payload: got %v", err)
+}
+
+// withByte returns a copy of data with the byte at i xored with mask
+func withByte(data []byte, i int, mask byte) []byte {
+	altered := append([]byte{}, data...)
+	altered[i] ^= mask
+	return altered
+}
@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// etmNonceSize is the nonce size of the encrypt-then-MAC construction, it is
+// independent of the block size so chunk nonces have room for a random prefix
+const etmNonceSize = 16
+
+// etmAEAD builds an AEAD cipher out of a block cipher with no AEAD mode of its
+// own, for the legacy ciphers. The plain text is encrypted in CTR mode and the
+// result is authenticated with HMAC-SHA-256 (encrypt-then-MAC). The encryption,
+// MAC and IV keys are derived separately from the key with HKDF-SHA-256. The
+// CTR IV is derived from the nonce, so the nonce only has to be unique.
+// Output takes the form ciphertext|tag where tag is
+// HMAC(macKey, len(additionalData)|additionalData|nonce|ciphertext).
+type etmAEAD struct {
+	block  cipher.Block
+	macKey []byte
+	ivKey  []byte
+}
+
+// newEtmAEAD derives the subkeys from key and creates the block cipher with
+// newBlock using a keySize long encryption key. name separates the subkeys
+// of different ciphers.
+func newEtmAEAD(name string, key []byte, keySize int, newBlock func(key []byte) (cipher.Block, error)) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("creating aead cipher: invalid key size")
+	}
+
+	kdf := hkdf.New(sha256.New, key, nil, []byte("krypt "+name+" etm"))
+	encKey := make([]byte, keySize)
+	macKey := make([]byte, 32)
+	ivKey := make([]byte, 32)
+	for _, subKey := range [][]byte{encKey, macKey, ivKey} {
+		if _, err := io.ReadFull(kdf, subKey); err != nil {
+			return nil, errors.Wrapf(err, "deriving subkeys")
+		}
+	}
+
+	block, err := newBlock(encKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating block cipher")
+	}
+	return &etmAEAD{block: block, macKey: macKey, ivKey: ivKey}, nil
+}
+
+func (a *etmAEAD) NonceSize() int {
+	return etmNonceSize
+}
+
+func (a *etmAEAD) Overhead() int {
+	return sha256.Size
+}
+
+func (a *etmAEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != etmNonceSize {
+		panic("crypto: incorrect nonce length given to encrypt-then-MAC")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+sha256.Size)
+	cipherText := out[:len(plaintext)]
+	cipher.NewCTR(a.block, a.iv(nonce)).XORKeyStream(cipherText, plaintext)
+	copy(out[len(plaintext):], a.tag(nonce, cipherText, additionalData))
+	return ret
+}
+
+func (a *etmAEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != etmNonceSize {
+		panic("crypto: incorrect nonce length given to encrypt-then-MAC")
+	}
+	if len(ciphertext) < sha256.Size {
+		return nil, errors.New("message authentication failed")
+	}
+
+	tagStart := len(ciphertext) - sha256.Size
+	if !hmac.Equal(ciphertext[tagStart:], a.tag(nonce, ciphertext[:tagStart], additionalData)) {
+		return nil, errors.New("message authentication failed")
+	}
+
+	ret, out := sliceForAppend(dst, tagStart)
+	cipher.NewCTR(a.block, a.iv(nonce)).XORKeyStream(out, ciphertext[:tagStart])
+	return ret, nil
+}
+
+// iv derives the CTR IV for the nonce
+func (a *etmAEAD) iv(nonce []byte) []byte {
+	mac := hmac.New(sha256.New, a.ivKey)
+	mac.Write(nonce)
+	return mac.Sum(nil)[:a.block.BlockSize()]
+}
+
+// tag computes the HMAC over the associated data, nonce and cipher text
+func (a *etmAEAD) tag(nonce, cipherText, additionalData []byte) []byte {
+	var adLen [8]byte
+	binary.BigEndian.PutUint64(adLen[:], uint64(len(additionalData)))
+
+	mac := hmac.New(sha256.New, a.macKey)
+	mac.Write(adLen[:])
+	mac.Write(additionalData)
+	mac.Write(nonce)
+	mac.Write(cipherText)
+	return mac.Sum(nil)
+}
+
+// sliceForAppend extends in by n bytes, returning the whole slice and the
+// extension, the same way the standard library AEAD ciphers do
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -0,0 +1,60 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtmTampered(t *testing.T) {
+	for _, c := range []Cipher{NewBlowfishCipher(), NewTripleDESCipher()} {
+		modeCipher, err := c.NewAEAD(bytes.Repeat([]byte{0x42}, keySize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce := bytes.Repeat([]byte{0x24}, modeCipher.NonceSize())
+		data := []byte("This is the test data to compare")
+
+		sealed := modeCipher.Seal(nil, nonce, data, []byte("header"))
+		opened, err := modeCipher.Open(nil, nonce, sealed, []byte("header"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, data, opened, "%s: opened data does not match", c.GetName())
+
+		_, err = modeCipher.Open(nil, nonce, sealed, []byte("headex"))
+		assert.Error(t, err, "%s: different associated data should not open", c.GetName())
+
+		for _, i := range []int{0, len(data), len(sealed) - 1} {
+			tampered := append([]byte{}, sealed...)
+			tampered[i] ^= 0x01
+			_, err = modeCipher.Open(nil, nonce, tampered, []byte("header"))
+			assert.Error(t, err, "%s: tampered byte %d should not open", c.GetName(), i)
+		}
+	}
+}
+
+func TestLegacyCipherDecryptOnly(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	pass := []byte("geronimo")
+
+	for _, cipherType := range []CipherType{BLOWFISH, TDES} {
+		assert.True(t, IsLegacyCipher(cipherType), "cipher should be legacy")
+
+		_, err := Encrypt(cipherType, pass, data)
+		assert.IsType(t, &LegacyCipherError{}, err, "legacy cipher should not encrypt by default")
+
+		encryptedData, err := EncryptWithOptions(cipherType, pass, data, Options{AllowLegacy: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decryptedData, err := Decrypt(pass, encryptedData)
+		if err != nil {
+			t.Fatal("error decrypting: ", err)
+		}
+		assert.Equal(t, data, decryptedData, "decrypted data does not match original data")
+	}
+	assert.False(t, IsLegacyCipher(AES256), "cipher should not be legacy")
+}
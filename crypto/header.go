@@ -0,0 +1,251 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// kryptMagic marks the start of every version 2 and later krypt container.
+// Version 1 containers start directly with their version byte.
+var kryptMagic = []byte("KRYPT")
+
+// header flags, see flagEnvelope
+const knownFlags = flagEnvelope
+
+// limits on header fields, used to reject garbage before allocating for it
+const (
+	maxHeaderFieldSize = 255
+	minChunkSize       = 16
+	maxChunkSize       = 16 * 1024 * 1024
+)
+
+// header is the metadata stored at the start of a krypt container. A version 2
+// header takes the form magic|version|cipher|flags|saltLen|salt|
+// noncePrefixLen|noncePrefix|chunkSize where lengths are single bytes and
+// multi-byte values are little endian. Every version 2 header has the
+// envelope flag, the key derivation of a password is kept in its stanza.
+// Version 1 headers only hold the version and cipher.
+type header struct {
+	version     uint8
+	cipherType  CipherType
+	flags       uint16
+	salt        []byte
+	noncePrefix []byte
+	chunkSize   uint32
+}
+
+// marshal returns the header as it is written to the start of a container
+func (h *header) marshal() ([]byte, error) {
+	buffer := new(bytes.Buffer)
+
+	if h.version == legacyVersion {
+		binary.Write(buffer, binary.LittleEndian, h.version)
+		binary.Write(buffer, binary.LittleEndian, h.cipherType)
+		return buffer.Bytes(), nil
+	}
+
+	for _, field := range [][]byte{h.salt, h.noncePrefix} {
+		if len(field) > maxHeaderFieldSize {
+			return nil, errors.New("header field too long")
+		}
+	}
+
+	buffer.Write(kryptMagic)
+	binary.Write(buffer, binary.LittleEndian, h.version)
+	binary.Write(buffer, binary.LittleEndian, h.cipherType)
+	binary.Write(buffer, binary.LittleEndian, h.flags)
+	writeField(buffer, h.salt)
+	writeField(buffer, h.noncePrefix)
+	binary.Write(buffer, binary.LittleEndian, h.chunkSize)
+
+	return buffer.Bytes(), nil
+}
+
+// readHeader reads a version 1 or 2 header from the start of a byte stream
+func readHeader(reader io.Reader) (*header, error) {
+	h := &header{}
+
+	var first [1]byte
+	if _, err := io.ReadFull(reader, first[:]); err == io.EOF {
+		return nil, NewDataIsNotEncryptedError()
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading krypt version")
+	}
+
+	switch {
+	case first[0] == legacyVersion:
+		h.version = legacyVersion
+	case first[0] == kryptMagic[0]:
+		magic := make([]byte, len(kryptMagic))
+		magic[0] = first[0]
+		if _, err := io.ReadFull(reader, magic[1:]); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, NewDataIsNotEncryptedError()
+		} else if err != nil {
+			return nil, errors.Wrap(err, "reading krypt magic")
+		}
+		if !bytes.Equal(magic, kryptMagic) {
+			return nil, NewDataIsNotEncryptedError()
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &h.version); err != nil {
+			return nil, readError(err, "reading krypt version")
+		}
+		if h.version != libVersion {
+			return nil, &UnsupportedVersionError{fmt.Sprintf("unsupported krypt version %d", h.version)}
+		}
+	default:
+		return nil, NewDataIsNotEncryptedError()
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &h.cipherType); err != nil {
+		return nil, readError(err, "reading krypt cipher")
+	}
+	if _, err := getCipher(h.cipherType); err != nil {
+		return nil, errors.Wrap(err, "cannot determine cipher used")
+	}
+	if h.version == legacyVersion && !v1Ciphers[h.cipherType] {
+		return nil, NewDataIsNotEncryptedError()
+	}
+
+	if h.version == legacyVersion {
+		return h, nil
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &h.flags); err != nil {
+		return nil, readError(err, "reading krypt flags")
+	}
+	if h.flags&^knownFlags != 0 {
+		return nil, &UnsupportedVersionError{"unknown krypt flags"}
+	}
+	if h.flags&flagEnvelope == 0 {
+		return nil, errors.New("krypt header has no key slots")
+	}
+
+	var err error
+	if h.salt, err = readField(reader); err != nil {
+		return nil, readError(err, "reading krypt salt")
+	}
+	if h.noncePrefix, err = readField(reader); err != nil {
+		return nil, readError(err, "reading krypt nonce")
+	}
+
+	if err := binary.Read(reader, binary.LittleEndian, &h.chunkSize); err != nil {
+		return nil, readError(err, "reading krypt chunk size")
+	}
+	if h.chunkSize < minChunkSize || h.chunkSize > maxChunkSize {
+		return nil, errors.New("invalid krypt chunk size")
+	}
+
+	return h, nil
+}
+
+// HeaderInfo describes sealed data as read from its start, without opening
+// it. KDF is only set for version 1 data, the password stanzas of data with
+// key slots each have their own. Size is the
+// length of the header, stanzas and header mac, the payload follows them.
+type HeaderInfo struct {
+	Version   uint8
+	Cipher    CipherType
+	Flags     uint16
+	KDF       KDF
+	ChunkSize int
+	Stanzas   []*Stanza
+	Size      int64
+}
+
+// ParseHeader reads the header of sealed data, and the stanzas and header mac
+// that follow it, leaving reader at the start of the payload
+func ParseHeader(reader io.Reader) (*HeaderInfo, error) {
+	counter := &countingReader{reader: reader}
+	h, err := readHeader(counter)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading krypt")
+	}
+
+	info := &HeaderInfo{Version: h.version, Cipher: h.cipherType, Flags: h.flags, ChunkSize: int(h.chunkSize)}
+	if h.version == legacyVersion {
+		info.KDF = NewPBKDF2KDF(legacyIterations)
+	} else {
+		if info.Stanzas, err = readStanzas(counter); err != nil {
+			return nil, readError(err, "reading krypt stanzas")
+		}
+		if _, err := io.ReadFull(counter, make([]byte, headerMACSize)); err != nil {
+			return nil, readError(err, "reading krypt header mac")
+		}
+	}
+	info.Size = counter.count
+	return info, nil
+}
+
+// HasKeySlots returns whether the data is sealed under a data key wrapped in
+// stanzas, rather than a key derived from a password as in version 1
+func (h *HeaderInfo) HasKeySlots() bool {
+	return h.Flags&flagEnvelope != 0
+}
+
+// PlainTextSize returns the size of the plain text sealed in a payload of
+// payloadSize bytes. Version 1 payloads, sealed in one piece, hold a salt of
+// their own and are not counted.
+func (h *HeaderInfo) PlainTextSize(payloadSize int64) (int64, bool) {
+	if h.Version == legacyVersion || h.ChunkSize <= 0 {
+		return 0, false
+	}
+	c, err := getCipher(h.Cipher)
+	if err != nil {
+		return 0, false
+	}
+	modeCipher, err := c.NewAEAD(make([]byte, keySize))
+	if err != nil {
+		return 0, false
+	}
+	overhead := int64(modeCipher.Overhead())
+	sealedChunkSize := int64(h.ChunkSize) + overhead
+	chunks := (payloadSize + sealedChunkSize - 1) / sealedChunkSize
+	if chunks == 0 || payloadSize-chunks*overhead < 0 {
+		return 0, false
+	}
+	return payloadSize - chunks*overhead, true
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// readError wraps an error from reading the named part of a container, data
+// that ends early is reported as a TruncatedError
+func readError(err error, what string) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = NewTruncatedError()
+	}
+	return errors.Wrap(err, what)
+}
+
+// writeField writes a field prefixed with its length
+func writeField(buffer *bytes.Buffer, field []byte) {
+	buffer.WriteByte(byte(len(field)))
+	buffer.Write(field)
+}
+
+// readField reads a field prefixed with its length
+func readField(reader io.Reader) ([]byte, error) {
+	var fieldLen uint8
+	if err := binary.Read(reader, binary.LittleEndian, &fieldLen); err != nil {
+		return nil, err
+	}
+	field := make([]byte, fieldLen)
+	if _, err := io.ReadFull(reader, field); err != nil {
+		return nil, err
+	}
+	return field, nil
+}
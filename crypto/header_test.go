@@ -0,0 +1,143 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	h := &header{
+		version:     libVersion,
+		cipherType:  TWOFISH,
+		flags:       flagEnvelope,
+		salt:        []byte("saltsaltsalt"),
+		noncePrefix: []byte("prefix!"),
+		chunkSize:   1024,
+	}
+	data, err := h.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, bytes.HasPrefix(data, kryptMagic), "header should start with magic")
+
+	found, err := readHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, h, found, "header mismatch")
+}
+
+func TestHeaderUnknownFlags(t *testing.T) {
+	h := &header{
+		version:     libVersion,
+		cipherType:  AES256,
+		flags:       flagEnvelope | 0x8000,
+		salt:        []byte("saltsaltsalt"),
+		noncePrefix: []byte("prefix!"),
+		chunkSize:   defaultChunkSize,
+	}
+	data, err := h.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = readHeader(bytes.NewReader(data))
+	assert.Error(t, err, "unknown flags should not be accepted")
+}
+
+func TestHeaderInvalidChunkSize(t *testing.T) {
+	for _, chunkSize := range []uint32{0, maxChunkSize + 1} {
+		h := &header{
+			version:     libVersion,
+			cipherType:  AES256,
+			flags:       flagEnvelope,
+			salt:        []byte("saltsaltsalt"),
+			noncePrefix: []byte("prefix!"),
+			chunkSize:   chunkSize,
+		}
+		data, err := h.marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = readHeader(bytes.NewReader(data))
+		assert.Error(t, err, "chunk size %d should not be accepted", chunkSize)
+	}
+}
+
+func TestHeaderTruncated(t *testing.T) {
+	data := mockKryptV2(t, libVersion, AES256)
+	headerLen := len(data) - len("unimportant data")
+
+	for i := 0; i < headerLen; i++ {
+		_, err := readHeader(bytes.NewReader(data[:i]))
+		assert.Error(t, err, "header cut at %d bytes should not be accepted", i)
+	}
+}
+
+func TestHeaderNoKeySlots(t *testing.T) {
+	h := &header{
+		version:     libVersion,
+		cipherType:  AES256,
+		salt:        []byte("saltsaltsalt"),
+		noncePrefix: []byte("prefix!"),
+		chunkSize:   defaultChunkSize,
+	}
+	data, err := h.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = readHeader(bytes.NewReader(data))
+	assert.Error(t, err, "a header without the envelope flag should not be accepted")
+}
+
+func TestParseHeader(t *testing.T) {
+	identity, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{KDF: NewPBKDF2KDF(1000), Recipients: []Recipient{identity.Recipient()}}
+	for _, size := range []int{0, 10, defaultChunkSize, 2*defaultChunkSize + 10} {
+		sealed, err := EncryptWithOptions(SERPENT, []byte("geronimo"), make([]byte, size), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reader := bytes.NewReader(sealed)
+		info, err := ParseHeader(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, libVersion, info.Version, "version mismatch")
+		assert.Equal(t, SERPENT, info.Cipher, "cipher mismatch")
+		assert.True(t, info.HasKeySlots(), "data should have key slots")
+		assert.Nil(t, info.KDF, "data with key slots should have no kdf")
+		assert.Equal(t, defaultChunkSize, info.ChunkSize, "chunk size mismatch")
+		assert.Len(t, info.Stanzas, 2, "stanza count mismatch")
+		assert.Equal(t, int64(len(sealed)-reader.Len()), info.Size, "size should end at the payload")
+
+		plainTextSize, ok := info.PlainTextSize(int64(reader.Len()))
+		assert.True(t, ok, "plain text size should be known")
+		assert.Equal(t, int64(size), plainTextSize, "plain text size mismatch")
+	}
+}
+
+func TestParseHeaderLegacy(t *testing.T) {
+	info, err := ParseHeader(bytes.NewReader(mockKrypt(legacyVersion, AES256, []byte("payload"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, legacyVersion, info.Version, "version mismatch")
+	assert.False(t, info.HasKeySlots(), "version 1 data should have no key slots")
+	assert.Equal(t, NewPBKDF2KDF(4096).GetParams(), info.KDF.GetParams(), "version 1 kdf mismatch")
+	assert.Equal(t, int64(2), info.Size, "version 1 header size mismatch")
+	_, ok := info.PlainTextSize(7)
+	assert.False(t, ok, "version 1 plain text size should not be known")
+
+	_, err = ParseHeader(bytes.NewReader([]byte("plain text")))
+	assert.True(t, errors.Is(err, ErrNotKrypt), "plain text should not parse: %v", err)
+}
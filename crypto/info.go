@@ -0,0 +1,118 @@
+package crypto
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Speed rates the throughput of a cipher relative to the others. The ratings
+// come from the Go implementations on amd64: fast ciphers run at hundreds of
+// MB/s, moderate ones at 30 to 100 MB/s and slow ones below that.
+type Speed int
+
+// relative cipher speeds
+const (
+	SpeedUnknown Speed = iota
+	SpeedSlow
+	SpeedModerate
+	SpeedFast
+)
+
+// String returns the name of the speed
+func (s Speed) String() string {
+	switch s {
+	case SpeedSlow:
+		return "slow"
+	case SpeedModerate:
+		return "moderate"
+	case SpeedFast:
+		return "fast"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the speed by its name
+func (s Speed) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// CipherInfo describes a cipher. Sizes are in bytes, BlockSize is 0 for
+// stream ciphers. EffectiveKeySize is only set for ciphers that do not use all
+// of their key, like the 168 of 192 bits of Triple-DES. AEAD is set for
+// ciphers with an authenticated mode, like GCM or Poly1305, rather than the
+// encrypt-then-MAC form of the legacy ciphers.
+type CipherInfo struct {
+	Type             CipherType `json:"type"`
+	Name             string     `json:"name"`
+	Aliases          []string   `json:"aliases,omitempty"`
+	Description      string     `json:"description"`
+	KeySize          int        `json:"key_size"`
+	EffectiveKeySize int        `json:"effective_key_size,omitempty"`
+	BlockSize        int        `json:"block_size"`
+	Mode             string     `json:"mode"`
+	Layers           []string   `json:"layers,omitempty"`
+	NonceSize        int        `json:"nonce_size"`
+	Overhead         int        `json:"overhead"`
+	AEAD             bool       `json:"aead"`
+	Deprecated       bool       `json:"deprecated"`
+	Speed            Speed      `json:"speed"`
+}
+
+// describedCipher is implemented by ciphers that describe their block size,
+// mode and speed
+type describedCipher interface {
+	GetInfo() CipherInfo
+}
+
+// GetCipherInfo returns the description of a registered cipher. The key and
+// block sizes, mode, layers, AEAD and speed come from the GetInfo() CipherInfo
+// method of the cipher, when it has one, the rest is filled in from the
+// registry and a cipher keyed with a zero key. Ciphers that do not tell their
+// key size are given the 256-bit keys krypt derives.
+func GetCipherInfo(cipherType CipherType) (CipherInfo, error) {
+	c, err := getCipher(cipherType)
+	if err != nil {
+		return CipherInfo{}, err
+	}
+
+	var info CipherInfo
+	if described, ok := c.(describedCipher); ok {
+		info = described.GetInfo()
+	}
+	info.Type = cipherType
+	info.Name = c.GetName()
+	info.Aliases = cipherAliases(cipherType)
+	info.Description = c.GetDescription()
+	if info.KeySize == 0 {
+		info.KeySize = keySize
+	}
+	info.Deprecated = info.Deprecated || IsLegacyCipher(cipherType)
+
+	modeCipher, err := c.NewAEAD(make([]byte, keySize))
+	if err != nil {
+		return CipherInfo{}, errors.Wrapf(err, "describing cipher %s", c.GetName())
+	}
+	info.NonceSize = modeCipher.NonceSize()
+	info.Overhead = modeCipher.Overhead()
+	return info, nil
+}
+
+// cipherAliases returns the names other than GetName the cipher type is
+// registered under, sorted
+func cipherAliases(cipherType CipherType) []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	c, ok := registry.byType[cipherType]
+	if !ok {
+		return nil
+	}
+	var aliases []string
+	for name, t := range registry.byName {
+		if t == cipherType && name != cipherKey(c.GetName()) {
+			aliases = append(aliases, name)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
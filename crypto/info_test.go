@@ -0,0 +1,84 @@
+package crypto
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCipherInfo(t *testing.T) {
+	info, err := GetCipherInfo(AES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "AES256", info.Name, "name mismatch")
+	assert.Equal(t, []string{"AES", "AES-256", "AES256-GCM"}, info.Aliases, "aliases mismatch")
+	assert.Equal(t, 32, info.KeySize, "key size mismatch")
+	assert.Equal(t, 16, info.BlockSize, "block size mismatch")
+	assert.Equal(t, "GCM", info.Mode, "mode mismatch")
+	assert.Equal(t, 12, info.NonceSize, "nonce size mismatch")
+	assert.Equal(t, 16, info.Overhead, "overhead mismatch")
+	assert.True(t, info.AEAD, "AES256 should be AEAD")
+	assert.False(t, info.Deprecated, "AES256 should not be deprecated")
+	assert.Equal(t, SpeedFast, info.Speed, "speed mismatch")
+
+	info, err = GetCipherInfo(XCHACHA20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 32, info.KeySize, "key size mismatch")
+	assert.Equal(t, 0, info.EffectiveKeySize, "the whole key should count")
+	assert.Equal(t, 0, info.BlockSize, "stream cipher should have no block size")
+	assert.Equal(t, 24, info.NonceSize, "nonce size mismatch")
+
+	info, err = GetCipherInfo(TDES)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 24, info.KeySize, "Triple-DES uses a 192-bit key")
+	assert.Equal(t, 21, info.EffectiveKeySize, "Triple-DES has 168 effective key bits")
+	assert.Equal(t, 8, info.BlockSize, "block size mismatch")
+	assert.False(t, info.AEAD, "legacy cipher should not be AEAD")
+	assert.True(t, info.Deprecated, "legacy cipher should be deprecated")
+
+	info, err = GetCipherInfo(AESTWOFISHSERPENT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"AES256", "TWOFISH", "SERPENT"}, info.Layers, "layers mismatch")
+	assert.Equal(t, 48, info.Overhead, "overhead mismatch")
+	assert.Equal(t, SpeedSlow, info.Speed, "speed mismatch")
+
+	_, err = GetCipherInfo(Unknown)
+	assert.Error(t, err, "unknown cipher should not be described")
+}
+
+func TestCipherInfoComplete(t *testing.T) {
+	for _, c := range GetCipherList() {
+		info, err := GetCipherInfo(c.GetType())
+		assert.Nil(t, err, "%s: unexpected error", c.GetName())
+		assert.NotEmpty(t, info.Mode, "%s: missing mode", c.GetName())
+		assert.NotEqual(t, SpeedUnknown, info.Speed, "%s: missing speed", c.GetName())
+		assert.NotZero(t, info.NonceSize, "%s: missing nonce size", c.GetName())
+	}
+}
+
+func TestCipherInfoJSON(t *testing.T) {
+	info, err := GetCipherInfo(BLOWFISH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "BLOWFISH", decoded["name"], "name mismatch")
+	assert.Equal(t, "moderate", decoded["speed"], "speed should be encoded by name")
+	assert.Equal(t, true, decoded["deprecated"], "deprecated mismatch")
+	assert.NotContains(t, decoded, "layers", "empty layers should be left out")
+}
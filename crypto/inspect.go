@@ -0,0 +1,113 @@
+package crypto
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// InspectSize is how much of the start of data Inspect needs to recognize it,
+// enough for the longest header in any encoding
+const InspectSize = 2048
+
+// lastVersion is the last version byte taken for a krypt container, data of
+// a later version than this krypt cannot be checked past its version
+const lastVersion = 15
+
+// v1MinSize is the least version 1 data holds: its version and cipher, and
+// the GCM nonce, tag and salt of the payload
+const v1MinSize = 2 + 12 + 16 + defaultSaltSize
+
+// Inspect recognizes sealed data from its start, the first InspectSize bytes
+// are enough. Binary data starts with the krypt magic and a header that
+// parses, or for version 1 with the version byte and one of the ciphers of
+// version 1. Text encoded data starts with the same once decoded, only base64
+// was used for version 1 data. Armored data starts with the armor begin line,
+// after any whitespace. Data that is not sealed is EncodingNone.
+//
+// The start of base64 and base64url text is often the same, they are told
+// apart by the characters only one of them uses anywhere in data, so more
+// than InspectSize bytes should be given when there are. Without those, text
+// that is not whole base64 quanta is unpadded base64url, and text that could
+// be either is base64.
+func Inspect(data []byte) Encoding {
+	start := data
+	if len(start) > InspectSize {
+		start = start[:InspectSize]
+	}
+	if isContainer(start) {
+		return EncodingBinary
+	}
+	if isArmor(start) {
+		return EncodingArmor
+	}
+	if decoded := decodeStart(start, EncodingBase64); isContainer(decoded) {
+		if !isBase64URL(data) {
+			return EncodingBase64
+		}
+		if bytes.HasPrefix(decoded, kryptMagic) {
+			return EncodingBase64URL
+		}
+	}
+	for _, encoding := range []Encoding{EncodingBase32, EncodingHex} {
+		if decoded := decodeStart(start, encoding); bytes.HasPrefix(decoded, kryptMagic) && isContainer(decoded) {
+			return encoding
+		}
+	}
+	return EncodingNone
+}
+
+// IsSealed returns whether data, or its start, is sealed data in any encoding
+func IsSealed(data []byte) bool {
+	return Inspect(data) != EncodingNone
+}
+
+// isContainer returns whether data starts like a binary krypt container. The
+// whole header must be in data, version 1 data must be long enough for its
+// payload.
+func isContainer(data []byte) bool {
+	switch {
+	case bytes.HasPrefix(data, kryptMagic):
+		if len(data) == len(kryptMagic) {
+			return false
+		}
+		if version := data[len(kryptMagic)]; version != libVersion {
+			return version > libVersion && version <= lastVersion
+		}
+		_, err := readHeader(bytes.NewReader(data))
+		return err == nil || errors.Is(err, ErrUnsupportedVersion)
+	case len(data) >= 2 && data[0] == legacyVersion:
+		return v1Ciphers[CipherType(data[1])] && len(data) >= v1MinSize
+	}
+	return false
+}
+
+// isBase64URL returns whether text of the base64 family is base64url, by the
+// characters only one alphabet uses, or failing those by its length: base64
+// is padded to whole quanta of 4 characters and base64url is not
+func isBase64URL(text []byte) bool {
+	if bytes.ContainsAny(text, "+/=") {
+		return false
+	}
+	if bytes.ContainsAny(text, "-_") {
+		return true
+	}
+	length := 0
+	for _, b := range text {
+		if !isTextSpace(b) {
+			length++
+		}
+	}
+	return length%4 != 0
+}
+
+// isArmor returns whether data starts like armored data, enough of the begin
+// line to tell it from other text is needed
+func isArmor(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	n := len(armorBegin)
+	if len(data) < n {
+		n = len(data)
+	}
+	return n >= len("-----BEGIN KRYPT") && string(data[:n]) == armorBegin[:n]
+}
@@ -0,0 +1,87 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspect(t *testing.T) {
+	sealed, err := EncryptWithOptions(AES256, []byte("geronimo"), []byte("This is the test data to compare"), Options{KDF: NewPBKDF2KDF(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ParseHeader(bytes.NewReader(sealed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// whole base64 quanta of a header without '+', '/', '-' or '_' in either
+	// encoding
+	mock := mockKryptV2(t, libVersion, AES256)
+	quanta := mock[:len(mock)/3*3]
+	legacy := mockKrypt(legacyVersion, SERPENT, bytes.Repeat([]byte("payload!"), 5))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding Encoding
+	}{
+		{"binary", sealed, EncodingBinary},
+		{"binary start", sealed[:info.Size], EncodingBinary},
+		{"base64", []byte(base64.StdEncoding.EncodeToString(sealed)), EncodingBase64},
+		{"armor", []byte(armorBegin + "\n" + base64.StdEncoding.EncodeToString(sealed)), EncodingArmor},
+		{"armor start", []byte("\r\n  -----BEGIN KRYPT"), EncodingArmor},
+		{"other armor", []byte("-----BEGIN PGP MESSAGE-----"), EncodingNone},
+		{"base64url", []byte(base64.RawURLEncoding.EncodeToString(sealed)), EncodingBase64URL},
+		{"base64 or base64url", []byte(base64.RawURLEncoding.EncodeToString(quanta)), EncodingBase64},
+		{"base64url without its characters", []byte(base64.RawURLEncoding.EncodeToString(mock)), EncodingBase64URL},
+		{"base32", []byte(strings.ToLower(base32.StdEncoding.EncodeToString(sealed))), EncodingBase32},
+		{"hex", []byte(hex.EncodeToString(sealed)), EncodingHex},
+		{"legacy", legacy, EncodingBinary},
+		{"legacy base64", []byte(base64.StdEncoding.EncodeToString(legacy)), EncodingBase64},
+		{"empty", nil, EncodingNone},
+		{"short magic", []byte("KRY"), EncodingNone},
+		{"plain text", []byte("This is the test data to compare"), EncodingNone},
+		{"base64 text", []byte(base64.StdEncoding.EncodeToString([]byte("not sealed at all"))), EncodingNone},
+		{"legacy hex", []byte(hex.EncodeToString(legacy)), EncodingNone},
+		{"legacy unknown cipher", mockKrypt(legacyVersion, CipherType(250), nil), EncodingNone},
+		{"legacy newer cipher", mockKrypt(legacyVersion, BLOWFISH, bytes.Repeat([]byte("payload!"), 5)), EncodingNone},
+		{"legacy too short", []byte("\x01\x02binarydata"), EncodingNone},
+		{"magic only", []byte("KRYPT"), EncodingNone},
+		{"magic text", []byte("KRYPTONITE is green"), EncodingNone},
+		{"magic hex text", []byte(hex.EncodeToString([]byte("KRYPTONITE is green"))), EncodingNone},
+		{"bad header", mockKryptV2(t, libVersion, CipherType(250)), EncodingNone},
+		{"newer version", []byte("KRYPT\x03"), EncodingBinary},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.encoding, Inspect(test.data), "%s: encoding mismatch", test.name)
+		assert.Equal(t, test.encoding != EncodingNone, IsSealed(test.data), "%s: sealed mismatch", test.name)
+	}
+}
+
+func TestInspectUnpadded(t *testing.T) {
+	// none of these hold '+', '/', '-' or '_', only their length tells the
+	// unpadded base64url ones from base64
+	mock := mockKryptV2(t, libVersion, AES256)
+	for _, data := range [][]byte{mock, mock[:len(mock)-1], mock[:len(mock)-2]} {
+		for _, text := range []string{base64.RawURLEncoding.EncodeToString(data), wrapText(base64.RawURLEncoding.EncodeToString(data))} {
+			encoding := Inspect([]byte(text))
+			if !assert.NotEqual(t, EncodingNone, encoding, "%d bytes: not recognized", len(data)) {
+				continue
+			}
+			decoder, err := NewDecoder(strings.NewReader(text), encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := ioutil.ReadAll(decoder)
+			assert.NoError(t, err, "%d bytes: decode failed", len(data))
+			assert.Equal(t, data, decoded, "%d bytes: data mismatch", len(data))
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDFType is the key derivation function used to turn a password into a key
+type KDFType uint8
+
+// kdf types
+const (
+	UnknownKDF KDFType = iota
+	PBKDF2
+	ARGON2ID
+	SCRYPT
+)
+
+const pbkdf2Name = "PBKDF2"
+const argon2idName = "ARGON2ID"
+const scryptName = "SCRYPT"
+
+// default parameters used for newly sealed data
+const (
+	defaultPBKDF2Iterations = 600000
+	defaultArgon2idTime     = 1
+	defaultArgon2idMemory   = 64 * 1024 // KiB
+	defaultArgon2idThreads  = 4
+	defaultScryptLogN       = 15
+	defaultScryptR          = 8
+	defaultScryptP          = 1
+)
+
+// upper bounds on the parameters read from a header, so a crafted file cannot
+// make us allocate or spin without limit
+const (
+	maxKDFMemory = 4 * 1024 * 1024 * 1024 // bytes
+	maxKDFTime   = 1 << 24
+)
+
+// KDF interface represents a password based key derivation function along
+// with its parameters
+type KDF interface {
+	DeriveKey(password []byte, salt []byte, keyLen int) ([]byte, error)
+	GetParams() []byte
+	GetDescription() string
+	GetName() string
+	GetType() KDFType
+}
+
+// getKDF gets the kdf object for the type and the parameters read from a header
+func getKDF(kdfType KDFType, params []byte) (kdf KDF, err error) {
+	switch kdfType {
+	case PBKDF2:
+		kdf, err = parsePBKDF2Params(params)
+	case ARGON2ID:
+		kdf, err = parseArgon2idParams(params)
+	case SCRYPT:
+		kdf, err = parseScryptParams(params)
+	default:
+		err = NewUnknownKDFTypeError()
+	}
+	return
+}
+
+// GetKDFByName gets the kdf by name with its default parameters
+func GetKDFByName(name string) (kdf KDF, err error) {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case pbkdf2Name:
+		kdf = NewPBKDF2KDF(defaultPBKDF2Iterations)
+	case argon2idName:
+		kdf = NewArgon2idKDF(defaultArgon2idTime, defaultArgon2idMemory, defaultArgon2idThreads)
+	case scryptName:
+		kdf = NewScryptKDF(defaultScryptLogN, defaultScryptR, defaultScryptP)
+	default:
+		err = NewUnknownKDFNameError()
+	}
+	return
+}
+
+// GetKDFList returns a list of the supported kdfs with their default parameters
+func GetKDFList() []KDF {
+	kdfList := []KDF{}
+	kdfList = append(kdfList, NewArgon2idKDF(defaultArgon2idTime, defaultArgon2idMemory, defaultArgon2idThreads))
+	kdfList = append(kdfList, NewScryptKDF(defaultScryptLogN, defaultScryptR, defaultScryptP))
+	kdfList = append(kdfList, NewPBKDF2KDF(defaultPBKDF2Iterations))
+	return kdfList
+}
+
+// defaultKDF is the kdf used when none is given
+func defaultKDF() KDF {
+	return NewArgon2idKDF(defaultArgon2idTime, defaultArgon2idMemory, defaultArgon2idThreads)
+}
+
+// PBKDF2KDF derives keys using HMAC-SHA-256 based PBKDF2
+type PBKDF2KDF struct {
+	description string
+	name        string
+	kdfType     KDFType
+	Iterations  uint32
+}
+
+// NewPBKDF2KDF constructor
+func NewPBKDF2KDF(iterations uint32) *PBKDF2KDF {
+	r := &PBKDF2KDF{}
+	r.description = "PBKDF2-HMAC-SHA256 (compatibility only)"
+	r.name = pbkdf2Name
+	r.kdfType = PBKDF2
+	r.Iterations = iterations
+
+	return r
+}
+
+// GetDescription returns description string
+func (k *PBKDF2KDF) GetDescription() string {
+	return k.description
+}
+
+// GetName returns name string
+func (k *PBKDF2KDF) GetName() string {
+	return k.name
+}
+
+// GetType returns KDFType
+func (k *PBKDF2KDF) GetType() KDFType {
+	return k.kdfType
+}
+
+// GetParams returns the parameters as stored in the header, in the form
+// iterations (4 bytes)
+func (k *PBKDF2KDF) GetParams() []byte {
+	params := make([]byte, 4)
+	binary.LittleEndian.PutUint32(params, k.Iterations)
+	return params
+}
+
+// DeriveKey derives a keyLen long key from password and salt
+func (k *PBKDF2KDF) DeriveKey(password []byte, salt []byte, keyLen int) ([]byte, error) {
+	if k.Iterations == 0 || k.Iterations > maxKDFTime {
+		return nil, errors.New("invalid pbkdf2 iterations")
+	}
+	return pbkdf2.Key(password, salt, int(k.Iterations), keyLen, sha256.New), nil
+}
+
+func parsePBKDF2Params(params []byte) (*PBKDF2KDF, error) {
+	if len(params) != 4 {
+		return nil, errors.New("invalid pbkdf2 params")
+	}
+	return NewPBKDF2KDF(binary.LittleEndian.Uint32(params)), nil
+}
+
+// Argon2idKDF derives keys using the memory-hard Argon2id function
+type Argon2idKDF struct {
+	description string
+	name        string
+	kdfType     KDFType
+	Time        uint32
+	Memory      uint32 // KiB
+	Threads     uint8
+}
+
+// NewArgon2idKDF constructor, memory is given in KiB
+func NewArgon2idKDF(time uint32, memory uint32, threads uint8) *Argon2idKDF {
+	r := &Argon2idKDF{}
+	r.description = "Argon2id memory-hard KDF"
+	r.name = argon2idName
+	r.kdfType = ARGON2ID
+	r.Time = time
+	r.Memory = memory
+	r.Threads = threads
+
+	return r
+}
+
+// GetDescription returns description string
+func (k *Argon2idKDF) GetDescription() string {
+	return k.description
+}
+
+// GetName returns name string
+func (k *Argon2idKDF) GetName() string {
+	return k.name
+}
+
+// GetType returns KDFType
+func (k *Argon2idKDF) GetType() KDFType {
+	return k.kdfType
+}
+
+// GetParams returns the parameters as stored in the header, in the form
+// time (4 bytes)|memory (4 bytes)|threads (1 byte)
+func (k *Argon2idKDF) GetParams() []byte {
+	params := make([]byte, 9)
+	binary.LittleEndian.PutUint32(params[0:], k.Time)
+	binary.LittleEndian.PutUint32(params[4:], k.Memory)
+	params[8] = k.Threads
+	return params
+}
+
+// DeriveKey derives a keyLen long key from password and salt
+func (k *Argon2idKDF) DeriveKey(password []byte, salt []byte, keyLen int) ([]byte, error) {
+	if k.Time == 0 || k.Time > maxKDFTime || k.Threads == 0 {
+		return nil, errors.New("invalid argon2id params")
+	}
+	if k.Memory < 8*uint32(k.Threads) || uint64(k.Memory)*1024 > maxKDFMemory {
+		return nil, errors.New("invalid argon2id memory")
+	}
+	return argon2.IDKey(password, salt, k.Time, k.Memory, k.Threads, uint32(keyLen)), nil
+}
+
+func parseArgon2idParams(params []byte) (*Argon2idKDF, error) {
+	if len(params) != 9 {
+		return nil, errors.New("invalid argon2id params")
+	}
+	return NewArgon2idKDF(binary.LittleEndian.Uint32(params[0:]),
+		binary.LittleEndian.Uint32(params[4:]), params[8]), nil
+}
+
+// ScryptKDF derives keys using the memory-hard scrypt function
+type ScryptKDF struct {
+	description string
+	name        string
+	kdfType     KDFType
+	LogN        uint8
+	R           uint32
+	P           uint32
+}
+
+// NewScryptKDF constructor, the cost parameter N is given as log2(N)
+func NewScryptKDF(logN uint8, r uint32, p uint32) *ScryptKDF {
+	k := &ScryptKDF{}
+	k.description = "scrypt memory-hard KDF"
+	k.name = scryptName
+	k.kdfType = SCRYPT
+	k.LogN = logN
+	k.R = r
+	k.P = p
+
+	return k
+}
+
+// GetDescription returns description string
+func (k *ScryptKDF) GetDescription() string {
+	return k.description
+}
+
+// GetName returns name string
+func (k *ScryptKDF) GetName() string {
+	return k.name
+}
+
+// GetType returns KDFType
+func (k *ScryptKDF) GetType() KDFType {
+	return k.kdfType
+}
+
+// GetParams returns the parameters as stored in the header, in the form
+// logN (1 byte)|r (4 bytes)|p (4 bytes)
+func (k *ScryptKDF) GetParams() []byte {
+	params := make([]byte, 9)
+	params[0] = k.LogN
+	binary.LittleEndian.PutUint32(params[1:], k.R)
+	binary.LittleEndian.PutUint32(params[5:], k.P)
+	return params
+}
+
+// DeriveKey derives a keyLen long key from password and salt
+func (k *ScryptKDF) DeriveKey(password []byte, salt []byte, keyLen int) ([]byte, error) {
+	if k.LogN == 0 || k.LogN > 32 || k.R == 0 || k.P == 0 {
+		return nil, errors.New("invalid scrypt params")
+	}
+	if uint64(k.R) > maxKDFMemory/128>>k.LogN || uint64(k.R)*uint64(k.P) >= 1<<30 {
+		return nil, errors.New("invalid scrypt params")
+	}
+	key, err := scrypt.Key(password, salt, 1<<k.LogN, int(k.R), int(k.P), keyLen)
+	if err != nil {
+		return nil, errors.Wrap(err, "deriving scrypt key")
+	}
+	return key, nil
+}
+
+func parseScryptParams(params []byte) (*ScryptKDF, error) {
+	if len(params) != 9 {
+		return nil, errors.New("invalid scrypt params")
+	}
+	return NewScryptKDF(params[0], binary.LittleEndian.Uint32(params[1:]),
+		binary.LittleEndian.Uint32(params[5:])), nil
+}
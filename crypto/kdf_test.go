@@ -0,0 +1,88 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKDFCrypt(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	pass := []byte("geronimo")
+
+	kdfs := []KDF{
+		NewPBKDF2KDF(1000),
+		NewArgon2idKDF(1, 8*1024, 2),
+		NewScryptKDF(10, 8, 1),
+	}
+	for _, kdf := range kdfs {
+		encryptedData, err := EncryptWithOptions(AES256, pass, data, Options{KDF: kdf})
+		if err != nil {
+			t.Fatalf("%s: %v", kdf.GetName(), err)
+		}
+
+		stanzas, err := ReadStanzas(bytes.NewReader(encryptedData))
+		if err != nil {
+			t.Fatal(err)
+		}
+		slotKDF, err := PasswordStanzaKDF(stanzas[0])
+		assert.Nil(t, err, "unexpected error")
+		assert.Equal(t, kdf, slotKDF, "kdf not recorded in the password slot")
+
+		decryptedData, err := Decrypt(pass, encryptedData)
+		if err != nil {
+			t.Fatalf("%s: %v", kdf.GetName(), err)
+		}
+		assert.Equal(t, data, decryptedData, "%s: decrypted data does not match", kdf.GetName())
+	}
+}
+
+func TestKDFParams(t *testing.T) {
+	kdfs := []KDF{
+		NewPBKDF2KDF(123456),
+		NewArgon2idKDF(3, 256*1024, 8),
+		NewScryptKDF(17, 16, 2),
+	}
+	for _, kdf := range kdfs {
+		found, err := getKDF(kdf.GetType(), kdf.GetParams())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, kdf, found, "kdf params mismatch")
+
+		_, err = getKDF(kdf.GetType(), kdf.GetParams()[1:])
+		assert.Error(t, err, "%s: short params should not parse", kdf.GetName())
+	}
+}
+
+func TestKDFInvalidParams(t *testing.T) {
+	kdfs := []KDF{
+		NewPBKDF2KDF(0),
+		NewArgon2idKDF(0, 64*1024, 4),
+		NewArgon2idKDF(1, 0xffffffff, 4),
+		NewArgon2idKDF(1, 64*1024, 0),
+		NewScryptKDF(0, 8, 1),
+		NewScryptKDF(40, 8, 1),
+		NewScryptKDF(30, 8, 1),
+		NewScryptKDF(10, 0xffffffff, 0xffffffff),
+	}
+	for _, kdf := range kdfs {
+		_, err := kdf.DeriveKey([]byte("geronimo"), []byte("saltsaltsalt"), keySize)
+		assert.Error(t, err, "%s %x: invalid params should not derive a key",
+			kdf.GetName(), kdf.GetParams())
+	}
+}
+
+func TestGetKDFByName(t *testing.T) {
+	kdf, err := GetKDFByName("no-kdf")
+	assert.EqualError(t, err, "kdf name not recognized", "unexpected error")
+	assert.Nil(t, kdf, "kdf should not be found")
+
+	for name, kdfType := range map[string]KDFType{
+		"PBKDF2": PBKDF2, "argon2id": ARGON2ID, " Scrypt ": SCRYPT} {
+		kdf, err = GetKDFByName(name)
+		assert.Nil(t, err, "unexpected error")
+		assert.Equal(t, kdfType, kdf.GetType(), "kdf types mismatch")
+	}
+}
@@ -2,15 +2,20 @@ package crypto
 
 import (
 	"bytes"
-	"encoding/binary"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
 	"io"
-	"strings"
+	"io/ioutil"
 
 	"github.com/pkg/errors"
+
+	"golang.org/x/crypto/pbkdf2"
 )
 
 const name = "krypt"
-const libVersion = uint8(1)
+const libVersion = uint8(2)
+const legacyVersion = uint8(1)
 
 const defaultSaltSize = 12
 
@@ -23,168 +28,256 @@ const (
 	AES256
 	TWOFISH
 	SERPENT
+	XCHACHA20
+	SECRETBOX
+	BLOWFISH
+	TDES
+	RC6
+	MARS
+	AESTWOFISH
+	AESTWOFISHSERPENT
+	SERPENTAES
+	SERPENTTWOFISHAES
+	TWOFISHSERPENT
 )
 
-// TODO: add secretbox https://godoc.org/golang.org/x/crypto/nacl/secretbox
-
-// TODO: find golang implementations of MARS & RC6
+// FirstCustomCipher is the first cipher type ciphers from other packages can
+// be registered under. The types below it are reserved for the ciphers of
+// krypt, present and future.
+const FirstCustomCipher CipherType = 128
 
-// For CDC mode ops
-// TODO: add blowfish https://godoc.org/golang.org/x/crypto/blowfish
-// TODO: add tripledes https://golang.org/pkg/crypto/des/
-// 						https://gist.github.com/cuixin/10612934
-
-// Cipher interface represents a en/decrypting module
+// Cipher interface represents a en/decrypting module, data is sealed and
+// opened in chunks with the AEAD it returns
 type Cipher interface {
-	Encrypt(data []byte, password []byte) ([]byte, error)
-	Decrypt(data []byte, password []byte) ([]byte, error)
+	NewAEAD(key []byte) (cipher.AEAD, error)
 	GetDescription() string
 	GetName() string
 	GetType() CipherType
 }
 
-// getCipher gets the cipher object type
-func getCipher(cipherType CipherType) (cipher Cipher, err error) {
-	switch cipherType {
-	case AES256:
-		cipher = NewAES256Cipher()
-	case TWOFISH:
-		cipher = NewTwofishCipher()
-	case SERPENT:
-		cipher = NewSerpentCipher()
-	default:
-		err = NewUnknownCipherTypeError()
-	}
-	return
-}
-
-// getCipherByName gets the cipher by name
-func getCipherByName(name string) (cipher Cipher, err error) {
-	switch name {
-	case aes256Name:
-		cipher = NewAES256Cipher()
-	case twofishName:
-		cipher = NewTwofishCipher()
-	case serpentName:
-		cipher = NewSerpentCipher()
-	default:
-		err = NewUnknownCipherNameError()
-	}
-	return
-}
-
-// GetCipherTypeByName gets the CipherType by name
-func GetCipherTypeByName(name string) (c CipherType, err error) {
-	//TODO: make this not care about whitespace or letter case
-	switch strings.TrimSpace(name) {
-	case aes256Name:
-		c = AES256
-	case twofishName:
-		c = TWOFISH
-	case serpentName:
-		c = SERPENT
-	default:
-		err = NewUnknownCipherNameError()
-	}
-	return
-}
-
-// GetCipherList returns a list of strings
+// getCipher gets the cipher registered for the type
+func getCipher(cipherType CipherType) (Cipher, error) {
+	c, ok := lookupCipher(cipherType)
+	if !ok {
+		return nil, NewUnknownCipherTypeError()
+	}
+	return c, nil
+}
+
+// getCipherByName gets the cipher registered for the name or alias
+func getCipherByName(name string) (Cipher, error) {
+	cipherType, err := GetCipherTypeByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return getCipher(cipherType)
+}
+
+// GetCipherTypeByName gets the CipherType by name or alias, regardless of
+// letter case and surrounding whitespace
+func GetCipherTypeByName(name string) (CipherType, error) {
+	cipherType, ok := lookupCipherType(name)
+	if !ok {
+		return Unknown, NewUnknownCipherNameError()
+	}
+	return cipherType, nil
+}
+
+// GetCipherList returns the registered ciphers
 func GetCipherList() []Cipher {
-	cipherList := []Cipher{}
-	cipherList = append(cipherList, NewAES256Cipher())
-	cipherList = append(cipherList, NewTwofishCipher())
-	cipherList = append(cipherList, NewSerpentCipher())
-	return cipherList
+	return registeredCiphers()
+}
+
+// legacyCipher is implemented by ciphers that should only be used to decrypt
+type legacyCipher interface {
+	IsLegacy() bool
+}
+
+// IsLegacyCipher returns true if the cipher is only kept to decrypt old data,
+// encrypting with it requires Options.AllowLegacy
+func IsLegacyCipher(cipherType CipherType) bool {
+	cipher, err := getCipher(cipherType)
+	if err != nil {
+		return false
+	}
+	legacy, ok := cipher.(legacyCipher)
+	return ok && legacy.IsLegacy()
+}
+
+// noAssociatedDataCipher is implemented by ciphers whose AEAD takes no
+// associated data, such as NaCl secretbox
+type noAssociatedDataCipher interface {
+	NoAssociatedData() bool
+}
+
+// takesAssociatedData returns whether the AEAD of the cipher can bind
+// associated data
+func takesAssociatedData(c Cipher) bool {
+	plain, ok := c.(noAssociatedDataCipher)
+	return !ok || !plain.NoAssociatedData()
+}
+
+// Options holds the optional settings used to encrypt and decrypt data
+type Options struct {
+	// KDF derives the key from the password, defaults to Argon2id
+	KDF KDF
+	// AdditionalData is bound to the encrypted data without being stored
+	AdditionalData []byte
+	// AllowLegacy allows encrypting with a legacy cipher
+	AllowLegacy bool
+	// Recipients seals the data under a random data key wrapped for each of
+	// the recipients, instead of a key derived from the password
+	Recipients []Recipient
+	// Envelope seals the data under the data key of an existing envelope,
+	// keeping its recipients. Recipients are added to it.
+	Envelope *Envelope
+	// Identities are tried in turn to open data sealed to recipients
+	Identities []Identity
+	// PasswordPrompt is called for the password when no password is given
+	// and the data turns out to be sealed with one
+	PasswordPrompt func() ([]byte, error)
+	// Keyfile is mixed with the password before the key derivation, see
+	// HashKeyfiles. Data sealed with one cannot be opened without it.
+	Keyfile []byte
 }
 
 // Encrypt data with password in the given CryptType format
 func Encrypt(cipherType CipherType, password []byte, data []byte) ([]byte, error) {
-	cipher, cerr := getCipher(cipherType)
-	if cerr != nil {
-		return nil, cerr
-	}
+	return EncryptWithAD(cipherType, password, data, nil)
+}
+
+// EncryptWithAD encrypts data like Encrypt, and binds additionalData (a file
+// 	path for example) to the result. The additional data is not stored, it
+// 	must be given again to DecryptWithAD.
+func EncryptWithAD(cipherType CipherType, password []byte, data []byte, additionalData []byte) ([]byte, error) {
+	return EncryptWithOptions(cipherType, password, data, Options{AdditionalData: additionalData})
+}
 
-	cipherText, err := cipher.Encrypt(data, password)
+// EncryptWithOptions encrypts data like Encrypt, using the given options
+func EncryptWithOptions(cipherType CipherType, password []byte, data []byte, opts Options) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	writer, err := NewEncryptWriterWithOptions(buffer, cipherType, password, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	kryptData := writeKrypt(cipherType, cipherText)
+	if _, err := writer.Write(data); err != nil {
+		return nil, errors.Wrap(err, "writing payload")
+	}
+	if err := writer.Close(); err != nil {
+		return nil, errors.Wrap(err, "writing payload")
+	}
 
-	return kryptData, nil
+	return buffer.Bytes(), nil
 }
 
 // Decrypt data block with the given password, encryption type
 // 	is derived from data block metadata
 func Decrypt(password []byte, data []byte) ([]byte, error) {
-	kryptVersion, cipherType, kerr := getKryptInfo(data)
-	if kerr != nil {
-		return nil, errors.Wrap(kerr, "reading krypt")
-	}
+	return DecryptWithAD(password, data, nil)
+}
 
-	cipher, cerr := getCipher(cipherType)
-	if cerr != nil {
-		return nil, cerr
-	}
+// DecryptWithAD decrypts data that was encrypted with EncryptWithAD using the
+// 	same additional data
+func DecryptWithAD(password []byte, data []byte, additionalData []byte) ([]byte, error) {
+	return DecryptWithOptions(password, data, Options{AdditionalData: additionalData})
+}
 
-	if kryptVersion != 1 {
-		return nil, errors.New("unknown krypt version")
+// DecryptWithOptions decrypts data like Decrypt, using the given options
+func DecryptWithOptions(password []byte, data []byte, opts Options) ([]byte, error) {
+	reader, err := NewDecryptReaderWithOptions(bytes.NewReader(data), password, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	payloadLen := len(data) - 2
-	if payloadLen == 0 {
-		return nil, errors.New("no payload found")
+	plainText, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypting payload")
 	}
-	reader := bytes.NewReader(data[2:])
-	payload := make([]byte, payloadLen)
-	if _, err := io.ReadFull(reader, payload); err != nil {
-		return nil, errors.Wrap(err, "reading payload")
+
+	return plainText, nil
+}
+
+// decryptLegacy decrypts a version 1 payload, which was sealed in one piece
+func decryptLegacy(cipher Cipher, password []byte, payload []byte) ([]byte, error) {
+	if len(payload) == 0 {
+		return nil, &TruncatedError{"no payload found"}
 	}
 
-	plainText, err := cipher.Decrypt(payload, password)
+	if !v1Ciphers[cipher.GetType()] {
+		return nil, NewDataIsNotEncryptedError()
+	}
+	plainText, err := decryptV1(cipher, payload, password)
 	if err != nil {
-		return nil, errors.Wrapf(err, "decrpyting payload")
+		return nil, &WrongPasswordError{"wrong password, or the data has been altered"}
 	}
 
 	return plainText, nil
 }
 
-// Write the data to a byte stream
-func writeKrypt(cipherType CipherType, data []byte) []byte {
-	// var buffer bytes.Buffer
-	buffer := new(bytes.Buffer)
+// legacyIterations is how many PBKDF2 iterations version 1 derived its keys
+// with, far too few for new data
+const legacyIterations = 4096
+
+// v1Ciphers are the only ciphers version 1 data was ever sealed with
+var v1Ciphers = map[CipherType]bool{AES256: true, TWOFISH: true, SERPENT: true}
+
+// encryptV1 seals data in one piece the way version 1 did, with the AEAD of
+// the cipher and a key derived from the password by PBKDF2. Output takes the
+// form nonce|ciphertext|tag|salt where '|' indicates concatenation. Only the
+// ciphers of version 1, see v1Ciphers, use it so that its data can be tested.
+func encryptV1(c Cipher, data []byte, password []byte) ([]byte, error) {
+	salt := make([]byte, defaultSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, errors.Wrapf(err, "randomizing salt")
+	}
+	key := pbkdf2.Key(password, salt, legacyIterations, keySize, sha256.New)
 
-	binary.Write(buffer, binary.LittleEndian, libVersion)
-	binary.Write(buffer, binary.LittleEndian, cipherType)
+	modeCipher, err := c.NewAEAD(key)
+	if err != nil {
+		return nil, err
+	}
 
-	buffer.Write(data)
+	nonce := make([]byte, modeCipher.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrapf(err, "randomizing nonce")
+	}
 
-	return buffer.Bytes()
+	cipherText := modeCipher.Seal(nonce, nonce, data, nil)
+	return append(cipherText, salt...), nil
 }
 
-func getKryptInfo(data []byte) (uint8, CipherType, error) {
-	dataLen := len(data)
-	if dataLen <= 2 {
-		return 0, 0, errors.New("krypt info missing")
+// decryptV1 opens data sealed in one piece by version 1, which takes the form
+// nonce|ciphertext|tag|salt where '|' indicates concatenation
+func decryptV1(c Cipher, data []byte, password []byte) ([]byte, error) {
+	if len(data) < defaultSaltSize {
+		return nil, errors.New("malformed ciphertext")
 	}
+	salt := data[len(data)-defaultSaltSize:]
+	key := pbkdf2.Key(password, salt, legacyIterations, keySize, sha256.New)
 
-	reader := bytes.NewReader(data)
-
-	var foundVersion uint8
-	if err := binary.Read(reader, binary.LittleEndian, &foundVersion); err != nil {
-		return 0, 0, errors.Wrap(err, "reading krypt version")
+	modeCipher, err := c.NewAEAD(key)
+	if err != nil {
+		return nil, err
 	}
-	if foundVersion != libVersion {
-		return 0, 0, errors.New("cannot read krypt info")
+	if len(data) < modeCipher.NonceSize()+defaultSaltSize {
+		return nil, errors.New("malformed ciphertext")
 	}
 
-	var foundCipherType uint8
-	if err := binary.Read(reader, binary.LittleEndian, &foundCipherType); err != nil {
-		return 0, 0, errors.Wrap(err, "reading krypt cipher")
+	nonce := data[:modeCipher.NonceSize()]
+	plainText, err := modeCipher.Open(nil, nonce, data[modeCipher.NonceSize():len(data)-defaultSaltSize], nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decrypting data")
 	}
-	if _, err := getCipher(CipherType(foundCipherType)); err != nil {
-		return 0, 0, errors.Wrap(err, "cannot determine cipher used")
+	return plainText, nil
+}
+
+// ReadCipherType reads the cipher sealed data was sealed with from its header,
+// without opening it
+func ReadCipherType(reader io.Reader) (CipherType, error) {
+	h, err := readHeader(reader)
+	if err != nil {
+		return Unknown, errors.Wrap(err, "reading krypt")
 	}
-	return foundVersion, CipherType(foundCipherType), nil
+	return h.cipherType, nil
 }
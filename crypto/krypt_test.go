@@ -1,6 +1,8 @@
 package crypto
 
 import (
+	"bytes"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -24,89 +26,157 @@ func TestKrypt(t *testing.T) {
 		"decrypted data does not match original data")
 }
 
+func TestKryptWithAD(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	pass := []byte("geronimo")
+	ad := []byte("/etc/krypt/secrets.yml")
+
+	encryptedData, err := EncryptWithAD(SERPENT, pass, data, ad)
+	if err != nil {
+		t.Fatal("error encrypting: ", err)
+	}
+
+	decryptedData, err := DecryptWithAD(pass, encryptedData, ad)
+	if err != nil {
+		t.Fatal("error decrypting: ", err)
+	}
+	assert.Equal(t, data, decryptedData, "decrypted data does not match original data")
+
+	_, err = DecryptWithAD(pass, encryptedData, []byte("/etc/krypt/other.yml"))
+	assert.Error(t, err, "different additional data should not decrypt")
+
+	_, err = Decrypt(pass, encryptedData)
+	assert.Error(t, err, "missing additional data should not decrypt")
+}
+
+func TestKryptHeaderTampered(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	pass := []byte("geronimo")
+
+	encryptedData, err := Encrypt(AES256, pass, data)
+	if err != nil {
+		t.Fatal("error encrypting: ", err)
+	}
+
+	// bump the chunk size, which leaves both key and chunk layout unchanged
+	h, err := readHeader(bytes.NewReader(encryptedData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headerData, _ := h.marshal()
+	h.chunkSize++
+	tamperedHeader, _ := h.marshal()
+	tamperedData := append(tamperedHeader, encryptedData[len(headerData):]...)
+
+	_, err = Decrypt(pass, tamperedData)
+	assert.Error(t, err, "tampered header should not decrypt")
+}
+
+func TestLegacyKryptWithAD(t *testing.T) {
+	pass := []byte("geronimo")
+	payload, err := NewAES256Cipher().Encrypt([]byte("test data"), pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = DecryptWithAD(pass, mockKrypt(legacyVersion, AES256, payload), []byte("context"))
+	assert.Error(t, err, "version 1 data cannot be bound to additional data")
+}
+
 func TestValidKryptHeader(t *testing.T) {
 	mockPayload := []byte("unimportant data")
-	mockData := mockKrypt(libVersion, AES256, mockPayload)
+	mockData := mockKrypt(legacyVersion, AES256, mockPayload)
 
-	kryptVersion, cipherType, err := getKryptInfo(mockData)
+	h, err := readHeader(bytes.NewReader(mockData))
 	if err != nil {
 		t.Fatal("error: ", err)
 	}
 
-	assert.Equal(t, libVersion, kryptVersion, "krypt version mismatch")
-	assert.Equal(t, AES256, cipherType, "cipher type mismatch")
+	assert.Equal(t, legacyVersion, h.version, "krypt version mismatch")
+	assert.Equal(t, AES256, h.cipherType, "cipher type mismatch")
+}
+
+func TestValidKryptV2Header(t *testing.T) {
+	mockData := mockKryptV2(t, libVersion, SERPENT)
+
+	h, err := readHeader(bytes.NewReader(mockData))
+	if err != nil {
+		t.Fatal("error: ", err)
+	}
+
+	assert.Equal(t, libVersion, h.version, "krypt version mismatch")
+	assert.Equal(t, SERPENT, h.cipherType, "cipher type mismatch")
+
+	cipherType, err := ReadCipherType(bytes.NewReader(mockData))
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, SERPENT, cipherType, "cipher type mismatch")
+}
+
+func TestInvalidKryptV2Version(t *testing.T) {
+	mockData := mockKryptV2(t, 3, AES256)
+
+	h, err := readHeader(bytes.NewReader(mockData))
+	assert.Error(t, err, "no error returned")
+	assert.Nil(t, h, "no header expected")
+}
+
+func TestInvalidKryptMagic(t *testing.T) {
+	mockData := mockKryptV2(t, libVersion, AES256)
+	mockData[1] = 'X'
+
+	h, err := readHeader(bytes.NewReader(mockData))
+	assert.Error(t, err, "no error returned")
+	assert.Nil(t, h, "no header expected")
 }
 
 func TestInvalidKryptVersion(t *testing.T) {
 	mockPayload := []byte("completely random data")
 	mockData := mockKrypt(255, AES256, mockPayload)
 
-	kryptVersion, cipherType, err := getKryptInfo(mockData)
-	if err == nil {
-		t.Fatal("no error returned")
-	}
-
-	assert.Equal(t, uint8(0), kryptVersion, "krypt version mismatch")
-	assert.Equal(t, CipherType(0), cipherType, "cipher type mismatch")
+	h, err := readHeader(bytes.NewReader(mockData))
+	assert.Error(t, err, "no error returned")
+	assert.Nil(t, h, "no header expected")
 }
 
 func TestInvalidCipherVersion(t *testing.T) {
 	mockPayload := []byte("completely random data")
-	mockData := mockKrypt(libVersion, 255, mockPayload)
+	mockData := mockKrypt(legacyVersion, 255, mockPayload)
 
-	kryptVersion, cipherType, err := getKryptInfo(mockData)
-	if err == nil {
-		t.Fatal("no error returned")
-	}
-
-	assert.Equal(t, uint8(0), kryptVersion, "krypt version mismatch")
-	assert.Equal(t, CipherType(0), cipherType, "cipher type mismatch")
+	h, err := readHeader(bytes.NewReader(mockData))
+	assert.Error(t, err, "no error returned")
+	assert.Nil(t, h, "no header expected")
 }
 
 func TestNoCipherVersion(t *testing.T) {
-	mockData := []byte{byte(libVersion)}
-
-	kryptVersion, cipherType, err := getKryptInfo(mockData)
-	if err == nil {
-		t.Fatal("no error returned")
-	}
+	mockData := []byte{byte(legacyVersion)}
 
-	assert.Equal(t, uint8(0), kryptVersion, "krypt version mismatch")
-	assert.Equal(t, CipherType(0), cipherType, "cipher type mismatch")
+	h, err := readHeader(bytes.NewReader(mockData))
+	assert.Error(t, err, "no error returned")
+	assert.Nil(t, h, "no header expected")
 }
 func TestNoPayload(t *testing.T) {
-	mockData := mockKrypt(libVersion, AES256, []byte{})
+	mockData := mockKrypt(legacyVersion, AES256, []byte{})
 
-	kryptVersion, cipherType, err := getKryptInfo(mockData)
-	if err == nil {
-		t.Fatal("no error returned")
-	}
-
-	assert.Equal(t, uint8(0), kryptVersion, "krypt version mismatch")
-	assert.Equal(t, CipherType(0), cipherType, "cipher type mismatch")
+	// the header is complete, the missing payload is found when opening
+	_, err := readHeader(bytes.NewReader(mockData))
+	assert.NoError(t, err, "header should be read")
+	_, err = Decrypt([]byte("geronimo"), mockData)
+	assert.True(t, errors.Is(err, ErrTruncated), "missing payload: got %v", err)
 }
 
 func TestEmptyKrypt(t *testing.T) {
 	mockData := []byte("")
 
-	kryptVersion, cipherType, err := getKryptInfo(mockData)
-	if err == nil {
-		t.Fatal("no error returned")
-	}
-
-	assert.Equal(t, uint8(0), kryptVersion, "krypt version mismatch")
-	assert.Equal(t, CipherType(0), cipherType, "cipher type mismatch")
+	h, err := readHeader(bytes.NewReader(mockData))
+	assert.Error(t, err, "no error returned")
+	assert.Nil(t, h, "no header expected")
 }
 
 func TestDataNotEncrypted(t *testing.T) {
 	mockData := []byte("completely random data")
-	kryptVersion, cipherType, err := getKryptInfo(mockData)
-	if err == nil {
-		t.Fatal("no error returned")
-	}
-
-	assert.Equal(t, uint8(0), kryptVersion, "krypt version mismatch")
-	assert.Equal(t, CipherType(0), cipherType, "cipher type mismatch")
+	h, err := readHeader(bytes.NewReader(mockData))
+	assert.Error(t, err, "no error returned")
+	assert.Nil(t, h, "no header expected")
 }
 
 func TestGetCipherTypeByName(t *testing.T) {
@@ -118,9 +188,17 @@ func TestGetCipherTypeByName(t *testing.T) {
 	assert.EqualError(t, err, "cipher name not recognized", "unexpected error")
 	assert.Equal(t, Unknown, cryptType, "crypt types mismatch")
 
-	// cryptType, err = GetCipherTypeByName("aes256")
-	// assert.Nil(t, err, "unexpected error")
-	// assert.Equal(t, AES256, cryptType, "crypt types mismatch")
+	cryptType, err = GetCipherTypeByName("aes256")
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, AES256, cryptType, "crypt types mismatch")
+
+	cryptType, err = GetCipherTypeByName(" Serpent-AES\n")
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, SERPENTAES, cryptType, "crypt types mismatch")
+
+	cryptType, err = GetCipherTypeByName("3des")
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, TDES, cryptType, "alias should find the cipher")
 
 	cryptType, err = GetCipherTypeByName("AES256")
 	assert.Nil(t, err, "unexpected error")
@@ -133,3 +211,19 @@ func mockKrypt(kryptVersion uint8, cipherType CipherType, data []byte) []byte {
 	buf = append(buf, data...)
 	return buf
 }
+
+func mockKryptV2(t *testing.T, kryptVersion uint8, cipherType CipherType) []byte {
+	h := &header{
+		version:     kryptVersion,
+		cipherType:  cipherType,
+		flags:       flagEnvelope,
+		salt:        []byte("saltsaltsalt"),
+		noncePrefix: []byte("prefix!"),
+		chunkSize:   defaultChunkSize,
+	}
+	data, err := h.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(data, []byte("unimportant data")...)
+}
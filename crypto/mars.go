@@ -0,0 +1,285 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"math/bits"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const marsName = "MARS"
+
+// MARSCipher encrypts using MARS-GCM
+type MARSCipher struct {
+	description string
+	name        string
+	cipherType  CipherType
+}
+
+// NewMARSCipher constructor
+func NewMARSCipher() *MARSCipher {
+	r := &MARSCipher{}
+	r.description = "MARS-GCM cipher"
+	r.name = marsName
+	r.cipherType = MARS
+
+	return r
+}
+
+// GetDescription returns description string
+func (c *MARSCipher) GetDescription() string {
+	return c.description
+}
+
+// GetName returns name string
+func (c *MARSCipher) GetName() string {
+	return c.name
+}
+
+// GetType returns CryptType
+func (c *MARSCipher) GetType() CipherType {
+	return c.cipherType
+}
+
+// GetInfo returns the block size, mode and speed of the cipher
+func (c *MARSCipher) GetInfo() CipherInfo {
+	return CipherInfo{KeySize: keySize, BlockSize: marsBlockSize, Mode: "GCM", AEAD: true, Speed: SpeedSlow}
+}
+
+// NewAEAD returns the MARS-GCM mode cipher keyed with key
+func (c *MARSCipher) NewAEAD(key []byte) (cipher.AEAD, error) {
+	blockCipher, err := newMARSBlock(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating block cipher")
+	}
+
+	modeCipher, err := cipher.NewGCM(blockCipher)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating block mode cipher")
+	}
+	return modeCipher, nil
+}
+
+const marsBlockSize = 16
+
+// marsKeySizeError is returned for keys MARS cannot be keyed with
+type marsKeySizeError int
+
+func (k marsKeySizeError) Error() string {
+	return "crypto/mars: invalid key size " + strconv.Itoa(int(k))
+}
+
+// marsBlock is the MARS block cipher as submitted to the AES competition,
+// with the revised key schedule. Words are little endian.
+type marsBlock struct {
+	k [40]uint32
+}
+
+// newMARSBlock returns MARS keyed with a 16 to 56 byte key, in 4 byte steps
+func newMARSBlock(key []byte) (cipher.Block, error) {
+	if len(key) < 16 || len(key) > 56 || len(key)%4 != 0 {
+		return nil, marsKeySizeError(len(key))
+	}
+	b := &marsBlock{}
+	b.expandKey(key)
+	return b, nil
+}
+
+func (b *marsBlock) BlockSize() int {
+	return marsBlockSize
+}
+
+// expandKey fills the 40 word expanded key
+func (b *marsBlock) expandKey(key []byte) {
+	n := len(key) / 4
+	var t [15]uint32
+	for i := 0; i < n; i++ {
+		t[i] = binary.LittleEndian.Uint32(key[4*i:])
+	}
+	t[n] = uint32(n)
+
+	for j := 0; j < 4; j++ {
+		for i := 0; i < 15; i++ {
+			t[i] ^= bits.RotateLeft32(t[(i+8)%15]^t[(i+13)%15], 3) ^ uint32(4*i+j)
+		}
+		for round := 0; round < 4; round++ {
+			for i := 0; i < 15; i++ {
+				t[i] = bits.RotateLeft32(t[i]+marsSBox[t[(i+14)%15]&511], 9)
+			}
+		}
+		for i := 0; i < 10; i++ {
+			b.k[10*j+i] = t[(4*i)%15]
+		}
+	}
+
+	// make sure the multiplication keys have no long runs of equal bits
+	fix := [4]uint32{0xa4a8d57b, 0x5b5d193b, 0xc8a8309b, 0x73f9a978}
+	for i := 5; i <= 35; i += 2 {
+		w := b.k[i] | 3
+		p := bits.RotateLeft32(fix[b.k[i]&3], int(b.k[i-1]&31))
+		b.k[i] = w ^ (p & marsMask(w))
+	}
+}
+
+// marsMask marks the bits of w that sit inside a run of ten or more equal bits,
+// leaving out the ends of each run and the lowest two and highest bit
+func marsMask(w uint32) uint32 {
+	var runs uint32
+	for l := 0; l < 32; {
+		r := l
+		for r+1 < 32 && (w>>uint(r+1))&1 == (w>>uint(l))&1 {
+			r++
+		}
+		if r-l+1 >= 10 {
+			runs |= (uint32(1)<<uint(r-l+1) - 1) << uint(l)
+		}
+		l = r + 1
+	}
+
+	var mask uint32
+	for l := 2; l <= 30; l++ {
+		bit := (w >> uint(l)) & 1
+		if (runs>>uint(l))&1 == 1 && (w>>uint(l-1))&1 == bit && (w>>uint(l+1))&1 == bit {
+			mask |= 1 << uint(l)
+		}
+	}
+	return mask
+}
+
+// marsE is the keyed E-function, returning the outputs l, m and r
+func (b *marsBlock) marsE(in uint32, round int) (uint32, uint32, uint32) {
+	m := in + b.k[2*round+4]
+	r := bits.RotateLeft32(in, 13) * b.k[2*round+5]
+	l := marsSBox[m&511]
+	r = bits.RotateLeft32(r, 5)
+	m = bits.RotateLeft32(m, int(r&31))
+	l ^= r
+	r = bits.RotateLeft32(r, 5)
+	l ^= r
+	l = bits.RotateLeft32(l, int(r&31))
+	return l, m, r
+}
+
+func (b *marsBlock) Encrypt(dst, src []byte) {
+	if len(src) < marsBlockSize || len(dst) < marsBlockSize {
+		panic("crypto/mars: input not full block")
+	}
+	var d [4]uint32
+	for i := range d {
+		d[i] = binary.LittleEndian.Uint32(src[4*i:]) + b.k[i]
+	}
+
+	// forward mixing
+	for i := 0; i < 8; i++ {
+		d[1] ^= marsSBox[d[0]&255]
+		d[1] += marsSBox[256+(d[0]>>8)&255]
+		d[2] += marsSBox[(d[0]>>16)&255]
+		d[3] ^= marsSBox[256+(d[0]>>24)]
+		d[0] = bits.RotateLeft32(d[0], -24)
+		if i == 0 || i == 4 {
+			d[0] += d[3]
+		}
+		if i == 1 || i == 5 {
+			d[0] += d[1]
+		}
+		d[0], d[1], d[2], d[3] = d[1], d[2], d[3], d[0]
+	}
+
+	// keyed core
+	for i := 0; i < 16; i++ {
+		l, m, r := b.marsE(d[0], i)
+		d[0] = bits.RotateLeft32(d[0], 13)
+		d[2] += m
+		if i < 8 {
+			d[1] += l
+			d[3] ^= r
+		} else {
+			d[3] += l
+			d[1] ^= r
+		}
+		d[0], d[1], d[2], d[3] = d[1], d[2], d[3], d[0]
+	}
+
+	// backwards mixing
+	for i := 0; i < 8; i++ {
+		if i == 2 || i == 6 {
+			d[0] -= d[3]
+		}
+		if i == 3 || i == 7 {
+			d[0] -= d[1]
+		}
+		d[1] ^= marsSBox[256+d[0]&255]
+		d[2] -= marsSBox[d[0]>>24]
+		d[3] -= marsSBox[256+(d[0]>>16)&255]
+		d[3] ^= marsSBox[(d[0]>>8)&255]
+		d[0] = bits.RotateLeft32(d[0], 24)
+		d[0], d[1], d[2], d[3] = d[1], d[2], d[3], d[0]
+	}
+
+	for i := range d {
+		binary.LittleEndian.PutUint32(dst[4*i:], d[i]-b.k[36+i])
+	}
+}
+
+func (b *marsBlock) Decrypt(dst, src []byte) {
+	if len(src) < marsBlockSize || len(dst) < marsBlockSize {
+		panic("crypto/mars: input not full block")
+	}
+	var d [4]uint32
+	for i := range d {
+		d[i] = binary.LittleEndian.Uint32(src[4*i:]) + b.k[36+i]
+	}
+
+	// undo backwards mixing
+	for i := 7; i >= 0; i-- {
+		d[0], d[1], d[2], d[3] = d[3], d[0], d[1], d[2]
+		d[0] = bits.RotateLeft32(d[0], -24)
+		d[3] ^= marsSBox[(d[0]>>8)&255]
+		d[3] += marsSBox[256+(d[0]>>16)&255]
+		d[2] += marsSBox[d[0]>>24]
+		d[1] ^= marsSBox[256+d[0]&255]
+		if i == 2 || i == 6 {
+			d[0] += d[3]
+		}
+		if i == 3 || i == 7 {
+			d[0] += d[1]
+		}
+	}
+
+	// undo keyed core
+	for i := 15; i >= 0; i-- {
+		d[0], d[1], d[2], d[3] = d[3], d[0], d[1], d[2]
+		d[0] = bits.RotateLeft32(d[0], -13)
+		l, m, r := b.marsE(d[0], i)
+		d[2] -= m
+		if i < 8 {
+			d[1] -= l
+			d[3] ^= r
+		} else {
+			d[3] -= l
+			d[1] ^= r
+		}
+	}
+
+	// undo forward mixing
+	for i := 7; i >= 0; i-- {
+		d[0], d[1], d[2], d[3] = d[3], d[0], d[1], d[2]
+		if i == 0 || i == 4 {
+			d[0] -= d[3]
+		}
+		if i == 1 || i == 5 {
+			d[0] -= d[1]
+		}
+		d[0] = bits.RotateLeft32(d[0], 24)
+		d[3] ^= marsSBox[256+(d[0]>>24)]
+		d[2] -= marsSBox[(d[0]>>16)&255]
+		d[1] -= marsSBox[256+(d[0]>>8)&255]
+		d[1] ^= marsSBox[d[0]&255]
+	}
+
+	for i := range d {
+		binary.LittleEndian.PutUint32(dst[4*i:], d[i]-b.k[i])
+	}
+}
@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMARSEncrypt(t *testing.T) {
+	cipher := NewMARSCipher()
+
+	data := []byte(`There is a theory which states that if ever anyone discovers
+exactly what the Universe is for and why it is here, it will
+instantly disappear and be replaced by something even more
+bizarre and inexplicable. There is another theory which states
+that this has already happened.`)
+	key := bytes.Repeat([]byte{0x42}, keySize)
+
+	modeCipher, err := cipher.NewAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, modeCipher.NonceSize())
+	encryptedData := modeCipher.Seal(nil, nonce, data, nil)
+	assert.NotEmpty(t, encryptedData, "no encrypted data")
+	assert.False(t, bytes.Compare(data, encryptedData[:len(data)]) == 0, "encrypted data should be different")
+
+	decryptedData, err := modeCipher.Open(nil, nonce, encryptedData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data, decryptedData, "decrypted data does not match original data")
+}
+
+// known answer tests from the MARS submission
+func TestMARSKnownAnswers(t *testing.T) {
+	vectors := []struct{ key, plain, cipher string }{
+		{"00000000000000000000000000000000",
+			"00000000000000000000000000000000", "dcc07b8dfb0738d6e30a22dfcf27e886"},
+		{"00000000000000000000000000000000",
+			"dcc07b8dfb0738d6e30a22dfcf27e886", "33caffbddc7f1dda0f9c15fa2f30e2ff"},
+		{"cb14a1776abbc1cdafe7243def2cea02",
+			"f94512a9b42d034ec4792204d708a69b", "225da2cb64b73f79069f21a5e3cb8522"},
+		{"86edf4da31824cabef6a4637c40b0bab",
+			"4df955ad5b398d66408d620a2b27e1a9", "a4b737340ae6d2cafd930ba97d86129f"},
+		{"d158860838874d9500000000000000000000000000000000",
+			"93a953a82c10411dd158860838874d95", "4fa0e5f64893131712f01408d233e9f7"},
+		{"791739a58b04581a93a953a82c10411dd158860838874d95",
+			"6761c42d3e6142d2a84fbfadb383158f", "f706bc0fd97e28b6f1af4e17d8755fff"},
+		{"fba167983e7aef22317ce28c02aae1a3e8e5cc3cedbea82a99dbc39ad65e7227",
+			"1344aba4d3c44708a8a72116d4f49384", "458335d95ea42a9f4dccd41aecc2390d"},
+	}
+	for _, v := range vectors {
+		key, _ := hex.DecodeString(v.key)
+		plain, _ := hex.DecodeString(v.plain)
+		block, err := newMARSBlock(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encrypted := make([]byte, marsBlockSize)
+		block.Encrypt(encrypted, plain)
+		assert.Equal(t, v.cipher, hex.EncodeToString(encrypted), "key %s: ciphertext mismatch", v.key)
+
+		decrypted := make([]byte, marsBlockSize)
+		block.Decrypt(decrypted, encrypted)
+		assert.Equal(t, plain, decrypted, "key %s: plaintext mismatch", v.key)
+	}
+}
+
+func TestMARSKeySize(t *testing.T) {
+	for _, size := range []int{0, 12, 18, 60} {
+		_, err := newMARSBlock(make([]byte, size))
+		assert.Error(t, err, "key size %d should not be accepted", size)
+	}
+}
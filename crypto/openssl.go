@@ -0,0 +1,134 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Data written by openssl enc takes the form "Salted__"|salt|ciphertext where
+// salt is 8 bytes and ciphertext is the CBC encryption of the PKCS#7 padded
+// plain text. The key and IV are derived from the password and salt, with
+// EVP_BytesToKey and a single iteration of the digest, or with PBKDF2 when
+// openssl enc was given -pbkdf2. The data is not authenticated, a wrong
+// password is only caught by its padding.
+var opensslMagic = []byte("Salted__")
+
+const opensslSaltSize = 8
+
+// OpenSSLOptions describes how openssl enc derived the key and IV of its data,
+// which is not stored along with it
+type OpenSSLOptions struct {
+	// Digest is the -md of openssl enc: md5, sha1 or sha256. It defaults to
+	// sha256, the default since OpenSSL 1.1.0; older versions used md5.
+	Digest string
+	// Iterations is the -iter of openssl enc -pbkdf2, which defaults to 10000
+	// there. Zero means the key and IV were derived with EVP_BytesToKey.
+	Iterations int
+}
+
+// opensslCiphers are the legacy ciphers whose openssl enc CBC data can be
+// opened, with the key size openssl enc uses for them
+var opensslCiphers = map[CipherType]struct {
+	keySize  int
+	newBlock func(key []byte) (cipher.Block, error)
+}{
+	BLOWFISH: {16, newBlowfishBlock},                     // bf-cbc
+	TDES:     {tripleDESKeySize, des.NewTripleDESCipher}, // des-ede3-cbc
+}
+
+// IsOpenSSLCipher returns true if data of the cipher written by openssl enc
+// can be opened with DecryptOpenSSL
+func IsOpenSSLCipher(cipherType CipherType) bool {
+	_, ok := opensslCiphers[cipherType]
+	return ok
+}
+
+// DecryptOpenSSL opens data that openssl enc sealed with the password, in CBC
+// mode of the legacy cipher, so that it can be sealed again by krypt. Data
+// that does not start with the openssl salt is a DataIsNotEncryptedError, and
+// data that does not unpad is a WrongPasswordError.
+func DecryptOpenSSL(cipherType CipherType, password []byte, data []byte, opts OpenSSLOptions) ([]byte, error) {
+	c, ok := opensslCiphers[cipherType]
+	if !ok {
+		return nil, errors.New("cipher cannot open openssl data")
+	}
+	newHash, err := opensslDigest(opts.Digest)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, opensslMagic) {
+		return nil, NewDataIsNotEncryptedError()
+	}
+	data = data[len(opensslMagic):]
+	if len(data) < opensslSaltSize {
+		return nil, &TruncatedError{"openssl salt is cut short"}
+	}
+	salt, cipherText := data[:opensslSaltSize], data[opensslSaltSize:]
+
+	// every cipher openssl enc can use here has 8 byte blocks
+	var keyIV []byte
+	if opts.Iterations > 0 {
+		keyIV = pbkdf2.Key(password, salt, opts.Iterations, c.keySize+8, newHash)
+	} else {
+		keyIV = evpBytesToKey(password, salt, newHash, c.keySize+8)
+	}
+	block, err := c.newBlock(keyIV[:c.keySize])
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating block cipher")
+	}
+	if len(cipherText) == 0 || len(cipherText)%block.BlockSize() != 0 {
+		return nil, &TruncatedError{"openssl data is cut short"}
+	}
+
+	plainText := make([]byte, len(cipherText))
+	cipher.NewCBCDecrypter(block, keyIV[c.keySize:]).CryptBlocks(plainText, cipherText)
+	padding := int(plainText[len(plainText)-1])
+	if padding == 0 || padding > block.BlockSize() {
+		return nil, &WrongPasswordError{"wrong password or options, or the data has been altered"}
+	}
+	for _, b := range plainText[len(plainText)-padding:] {
+		if int(b) != padding {
+			return nil, &WrongPasswordError{"wrong password or options, or the data has been altered"}
+		}
+	}
+	return plainText[:len(plainText)-padding], nil
+}
+
+// opensslDigest returns the hash of an openssl enc -md name
+func opensslDigest(name string) (func() hash.Hash, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "sha256":
+		return sha256.New, nil
+	case "sha1":
+		return sha1.New, nil
+	case "md5":
+		return md5.New, nil
+	}
+	return nil, errors.Errorf("unknown openssl digest %q", name)
+}
+
+// evpBytesToKey derives size bytes of key and IV from the password and salt
+// the way EVP_BytesToKey of OpenSSL does with a single iteration:
+// D_i = HASH(D_(i-1)|password|salt), concatenated.
+func evpBytesToKey(password []byte, salt []byte, newHash func() hash.Hash, size int) []byte {
+	var derived, last []byte
+	for len(derived) < size {
+		h := newHash()
+		h.Write(last)
+		h.Write(password)
+		h.Write(salt)
+		last = h.Sum(nil)
+		derived = append(derived, last...)
+	}
+	return derived[:size]
+}
@@ -0,0 +1,65 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// made with: openssl enc ARGS -pass pass:geronimo of the test data
+var opensslTests = []struct {
+	args       string
+	cipherType CipherType
+	opts       OpenSSLOptions
+	sealed     string
+}{
+	{"-bf-cbc -md md5", BLOWFISH, OpenSSLOptions{Digest: "md5"},
+		"53616c7465645f5fd8509a4f64b38a99e75c2182cce3f1c440361af2d0a0ff07e5e721dc122c7b7a1329fc5fa06ac47eef247386d3c08cf6"},
+	{"-bf-cbc -pbkdf2 -iter 1000 -md sha256", BLOWFISH, OpenSSLOptions{Iterations: 1000},
+		"53616c7465645f5f20a477d8c6a6e0d536fab5064b3d6c9006e315b7b7bc7dccc31174827c5badafc869611980e0f462e6bf3d245faeae10"},
+	{"-des-ede3-cbc -md sha256", TDES, OpenSSLOptions{Digest: "SHA256"},
+		"53616c7465645f5fa15cc109df5c6a963e48e5f391a65e9d30f973d416459719ff6e24353912abc25c79fe0930a2ef3b0bd2dd84e55ee53d"},
+	{"-des-ede3-cbc -pbkdf2 -md sha256", TDES, OpenSSLOptions{Iterations: 10000},
+		"53616c7465645f5f99aa2b78867a4c12808f1311febeaf04ca3b6c67de1ccdc66acbb5a43c9545d5731d7647775a9c30873883451da55329"},
+}
+
+func TestDecryptOpenSSL(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	for _, test := range opensslTests {
+		sealed, err := hex.DecodeString(test.sealed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, IsOpenSSLCipher(test.cipherType), "%s: cipher should open openssl data", test.args)
+
+		decrypted, err := DecryptOpenSSL(test.cipherType, []byte("geronimo"), sealed, test.opts)
+		assert.NoError(t, err, "%s: decrypt failed", test.args)
+		assert.Equal(t, data, decrypted, "%s: data mismatch", test.args)
+
+		_, err = DecryptOpenSSL(test.cipherType, []byte("cowabunga"), sealed, test.opts)
+		assert.True(t, errors.Is(err, ErrWrongPassword), "%s: wrong password: got %v", test.args, err)
+	}
+}
+
+func TestDecryptOpenSSLErrors(t *testing.T) {
+	sealed, err := hex.DecodeString(opensslTests[0].sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pass := []byte("geronimo")
+	opts := opensslTests[0].opts
+
+	_, err = DecryptOpenSSL(BLOWFISH, pass, []byte("This is the test data to compare"), opts)
+	assert.True(t, errors.Is(err, ErrNotKrypt), "not openssl data: got %v", err)
+	_, err = DecryptOpenSSL(BLOWFISH, pass, sealed[:12], opts)
+	assert.True(t, errors.Is(err, ErrTruncated), "cut short salt: got %v", err)
+	_, err = DecryptOpenSSL(BLOWFISH, pass, sealed[:len(sealed)-3], opts)
+	assert.True(t, errors.Is(err, ErrTruncated), "cut short data: got %v", err)
+	_, err = DecryptOpenSSL(BLOWFISH, pass, sealed, OpenSSLOptions{Digest: "md4"})
+	assert.Error(t, err, "unknown digest should fail")
+	_, err = DecryptOpenSSL(AES256, pass, sealed, opts)
+	assert.Error(t, err, "cipher without openssl data should fail")
+	assert.False(t, IsOpenSSLCipher(AES256), "cipher should not open openssl data")
+}
@@ -0,0 +1,169 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// password stanza flags, stored in an optional fifth field
+const passwordFlagKeyfile = byte(1 << 0)
+
+// PasswordRecipient wraps data keys under a key derived from a password, so a
+// password is a key slot like any recipient. The stanza holds the kdf, its
+// params and salt, and the data key sealed with ChaCha20-Poly1305.
+type PasswordRecipient struct {
+	password []byte
+	keyfile  []byte
+	kdf      KDF
+}
+
+// NewPasswordRecipient creates a recipient for the password, its key is
+// derived with kdf or Argon2id when kdf is nil
+func NewPasswordRecipient(password []byte, kdf KDF) *PasswordRecipient {
+	return NewPasswordRecipientWithKeyfile(password, nil, kdf)
+}
+
+// NewPasswordRecipientWithKeyfile works like NewPasswordRecipient, with the
+// keyfile mixed into the password so that both are needed to unwrap
+func NewPasswordRecipientWithKeyfile(password []byte, keyfile []byte, kdf KDF) *PasswordRecipient {
+	if kdf == nil {
+		kdf = defaultKDF()
+	}
+	return &PasswordRecipient{
+		password: append([]byte{}, password...),
+		keyfile:  append([]byte{}, keyfile...),
+		kdf:      kdf,
+	}
+}
+
+// Wrap seals the data key under a key derived from the password
+func (r *PasswordRecipient) Wrap(dataKey []byte) (*Stanza, error) {
+	if len(r.password) == 0 {
+		return nil, errors.New("empty password")
+	}
+
+	salt := make([]byte, defaultSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, errors.Wrapf(err, "randomizing salt")
+	}
+	wrapKey, err := r.kdf.DeriveKey(mixKeyfile(r.password, r.keyfile), salt, keySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "deriving wrap key")
+	}
+	wrapped, err := wrapDataKey(wrapKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	fields := [][]byte{{byte(r.kdf.GetType())}, r.kdf.GetParams(), salt, wrapped}
+	if len(r.keyfile) > 0 {
+		fields = append(fields, []byte{passwordFlagKeyfile})
+	}
+	return &Stanza{Type: PasswordStanza, Fields: fields}, nil
+}
+
+// PasswordIdentity unwraps data keys sealed under a password
+type PasswordIdentity struct {
+	password []byte
+	keyfile  []byte
+}
+
+// NewPasswordIdentity creates an identity for the password
+func NewPasswordIdentity(password []byte) *PasswordIdentity {
+	return NewPasswordIdentityWithKeyfile(password, nil)
+}
+
+// NewPasswordIdentityWithKeyfile creates an identity for the password and
+// keyfile. The keyfile is only used for stanzas that need one, without a
+// keyfile those are skipped.
+func NewPasswordIdentityWithKeyfile(password []byte, keyfile []byte) *PasswordIdentity {
+	return &PasswordIdentity{password: append([]byte{}, password...), keyfile: append([]byte{}, keyfile...)}
+}
+
+// Unwrap opens the data key from a stanza sealed under the password
+func (i *PasswordIdentity) Unwrap(stanza *Stanza) ([]byte, error) {
+	if stanza.Type != PasswordStanza {
+		return nil, nil
+	}
+	kdf, err := passwordStanzaKDF(stanza)
+	if err != nil {
+		return nil, err
+	}
+	var keyfile []byte
+	if PasswordStanzaKeyfile(stanza) {
+		if len(i.keyfile) == 0 {
+			return nil, nil
+		}
+		keyfile = i.keyfile
+	}
+	wrapKey, err := kdf.DeriveKey(mixKeyfile(i.password, keyfile), stanza.Fields[2], keySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "deriving wrap key")
+	}
+	return unwrapDataKey(wrapKey, stanza.Fields[3])
+}
+
+// PasswordStanzaKDF returns the key derivation function of a password stanza
+func PasswordStanzaKDF(stanza *Stanza) (KDF, error) {
+	if stanza.Type != PasswordStanza {
+		return nil, errors.New("not a password stanza")
+	}
+	return passwordStanzaKDF(stanza)
+}
+
+// PasswordStanzaKeyfile returns whether a password stanza needs a keyfile
+func PasswordStanzaKeyfile(stanza *Stanza) bool {
+	if stanza.Type != PasswordStanza || len(stanza.Fields) != 5 || len(stanza.Fields[4]) != 1 {
+		return false
+	}
+	return stanza.Fields[4][0]&passwordFlagKeyfile != 0
+}
+
+func passwordStanzaKDF(stanza *Stanza) (KDF, error) {
+	if len(stanza.Fields) < 4 || len(stanza.Fields) > 5 || len(stanza.Fields[0]) != 1 || len(stanza.Fields[2]) == 0 {
+		return nil, errors.New("malformed password stanza")
+	}
+	if len(stanza.Fields) == 5 && (len(stanza.Fields[4]) != 1 || stanza.Fields[4][0]&^passwordFlagKeyfile != 0) {
+		return nil, errors.New("malformed password stanza")
+	}
+	return getKDF(KDFType(stanza.Fields[0][0]), stanza.Fields[1])
+}
+
+// HashKeyfiles hashes the contents of one or more keyfiles, of any size, into
+// the keyfile to seal and open with. The order of the keyfiles does not matter.
+func HashKeyfiles(keyfiles ...io.Reader) ([]byte, error) {
+	if len(keyfiles) == 0 {
+		return nil, errors.New("no keyfiles")
+	}
+	digests := make([][]byte, len(keyfiles))
+	for i, keyfile := range keyfiles {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, keyfile); err != nil {
+			return nil, errors.Wrap(err, "reading keyfile")
+		}
+		digests[i] = hash.Sum(nil)
+	}
+	sort.Slice(digests, func(a, b int) bool { return bytes.Compare(digests[a], digests[b]) < 0 })
+
+	hash := sha256.New()
+	hash.Write([]byte("krypt keyfile"))
+	for _, digest := range digests {
+		hash.Write(digest)
+	}
+	return hash.Sum(nil), nil
+}
+
+// mixKeyfile mixes the keyfile into the password before the key derivation
+func mixKeyfile(password []byte, keyfile []byte) []byte {
+	if len(keyfile) == 0 {
+		return password
+	}
+	mac := hmac.New(sha256.New, keyfile)
+	mac.Write(password)
+	return mac.Sum(nil)
+}
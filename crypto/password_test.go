@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashKeyfiles(t *testing.T) {
+	first := bytes.Repeat([]byte("first keyfile"), 100000)
+	second := []byte{0, 1, 2, 3}
+
+	keyfile, err := HashKeyfiles(bytes.NewReader(first), bytes.NewReader(second))
+	assert.Nil(t, err, "unexpected error")
+	assert.Len(t, keyfile, 32, "keyfile size mismatch")
+
+	reordered, err := HashKeyfiles(bytes.NewReader(second), bytes.NewReader(first))
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, keyfile, reordered, "keyfile order should not matter")
+
+	single, err := HashKeyfiles(bytes.NewReader(first))
+	assert.Nil(t, err, "unexpected error")
+	assert.NotEqual(t, keyfile, single, "every keyfile should count")
+
+	_, err = HashKeyfiles()
+	assert.Error(t, err, "no keyfiles should not hash")
+}
+
+func TestPasswordKeyfile(t *testing.T) {
+	keyfile, err := HashKeyfiles(bytes.NewReader([]byte("the keyfile")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKeyfile, err := HashKeyfiles(bytes.NewReader([]byte("another keyfile")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plainText := []byte("two factors")
+	opts := Options{KDF: NewPBKDF2KDF(1000), Keyfile: keyfile}
+	sealed, err := EncryptWithOptions(AES256, []byte("geronimo"), plainText, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stanzas, err := ReadStanzas(bytes.NewReader(sealed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, PasswordStanzaKeyfile(stanzas[0]), "stanza should need a keyfile")
+
+	opened, err := DecryptWithOptions([]byte("geronimo"), sealed, Options{Keyfile: keyfile})
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, plainText, opened, "plain text mismatch")
+
+	_, err = DecryptWithOptions([]byte("geronimo"), sealed, Options{})
+	assert.IsType(t, &KeyfileRequiredError{}, err, "password alone should need the keyfile")
+	_, err = DecryptWithOptions([]byte("geronimo"), sealed, Options{Keyfile: otherKeyfile})
+	assert.IsType(t, &NoIdentityMatchError{}, err, "other keyfile should not decrypt")
+	_, err = DecryptWithOptions([]byte("cowabunga"), sealed, Options{Keyfile: keyfile})
+	assert.IsType(t, &NoIdentityMatchError{}, err, "keyfile alone should not decrypt")
+
+	// the password is not asked for when the keyfile is missing
+	prompted := false
+	prompt := func() ([]byte, error) {
+		prompted = true
+		return []byte("geronimo"), nil
+	}
+	_, err = DecryptWithOptions(nil, sealed, Options{PasswordPrompt: prompt})
+	assert.IsType(t, &KeyfileRequiredError{}, err, "missing keyfile should be reported")
+	assert.False(t, prompted, "password should not be asked for without the keyfile")
+
+	// a keyfile does not get in the way of a password slot without one
+	sealed, err = EncryptWithOptions(AES256, []byte("geronimo"), plainText, Options{KDF: NewPBKDF2KDF(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err = DecryptWithOptions([]byte("geronimo"), sealed, Options{Keyfile: keyfile})
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, plainText, opened, "plain text mismatch")
+}
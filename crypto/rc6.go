@@ -0,0 +1,169 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"math/bits"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const rc6Name = "RC6"
+
+// RC6Cipher encrypts using RC6-GCM
+type RC6Cipher struct {
+	description string
+	name        string
+	cipherType  CipherType
+}
+
+// NewRC6Cipher constructor
+func NewRC6Cipher() *RC6Cipher {
+	r := &RC6Cipher{}
+	r.description = "RC6-GCM cipher"
+	r.name = rc6Name
+	r.cipherType = RC6
+
+	return r
+}
+
+// GetDescription returns description string
+func (c *RC6Cipher) GetDescription() string {
+	return c.description
+}
+
+// GetName returns name string
+func (c *RC6Cipher) GetName() string {
+	return c.name
+}
+
+// GetType returns CryptType
+func (c *RC6Cipher) GetType() CipherType {
+	return c.cipherType
+}
+
+// GetInfo returns the block size, mode and speed of the cipher
+func (c *RC6Cipher) GetInfo() CipherInfo {
+	return CipherInfo{KeySize: keySize, BlockSize: rc6BlockSize, Mode: "GCM", AEAD: true, Speed: SpeedModerate}
+}
+
+// NewAEAD returns the RC6-GCM mode cipher keyed with key
+func (c *RC6Cipher) NewAEAD(key []byte) (cipher.AEAD, error) {
+	blockCipher, err := newRC6Block(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating block cipher")
+	}
+
+	modeCipher, err := cipher.NewGCM(blockCipher)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating block mode cipher")
+	}
+	return modeCipher, nil
+}
+
+const (
+	rc6BlockSize = 16
+	rc6Rounds    = 20
+	rc6P32       = 0xb7e15163
+	rc6Q32       = 0x9e3779b9
+)
+
+// rc6KeySizeError is returned for keys RC6 cannot be keyed with
+type rc6KeySizeError int
+
+func (k rc6KeySizeError) Error() string {
+	return "crypto/rc6: invalid key size " + strconv.Itoa(int(k))
+}
+
+// rc6Block is RC6-32/20 as submitted to the AES competition. Words are little
+// endian.
+type rc6Block struct {
+	s [2*rc6Rounds + 4]uint32
+}
+
+// newRC6Block returns RC6 keyed with a 16, 24 or 32 byte key
+func newRC6Block(key []byte) (cipher.Block, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, rc6KeySizeError(len(key))
+	}
+	r := &rc6Block{}
+	r.expandKey(key)
+	return r, nil
+}
+
+func (r *rc6Block) BlockSize() int {
+	return rc6BlockSize
+}
+
+// expandKey fills the round keys from the user key
+func (r *rc6Block) expandKey(key []byte) {
+	l := make([]uint32, len(key)/4)
+	for i := range l {
+		l[i] = binary.LittleEndian.Uint32(key[4*i:])
+	}
+
+	r.s[0] = rc6P32
+	for i := 1; i < len(r.s); i++ {
+		r.s[i] = r.s[i-1] + rc6Q32
+	}
+
+	var x, y uint32
+	i, j := 0, 0
+	for k := 0; k < 3*len(r.s); k++ {
+		r.s[i] = bits.RotateLeft32(r.s[i]+x+y, 3)
+		x = r.s[i]
+		l[j] = bits.RotateLeft32(l[j]+x+y, int((x+y)&31))
+		y = l[j]
+		i = (i + 1) % len(r.s)
+		j = (j + 1) % len(l)
+	}
+}
+
+func (r *rc6Block) Encrypt(dst, src []byte) {
+	if len(src) < rc6BlockSize || len(dst) < rc6BlockSize {
+		panic("crypto/rc6: input not full block")
+	}
+	a := binary.LittleEndian.Uint32(src[0:])
+	b := binary.LittleEndian.Uint32(src[4:]) + r.s[0]
+	c := binary.LittleEndian.Uint32(src[8:])
+	d := binary.LittleEndian.Uint32(src[12:]) + r.s[1]
+
+	for i := 1; i <= rc6Rounds; i++ {
+		t := bits.RotateLeft32(b*(2*b+1), 5)
+		u := bits.RotateLeft32(d*(2*d+1), 5)
+		a = bits.RotateLeft32(a^t, int(u&31)) + r.s[2*i]
+		c = bits.RotateLeft32(c^u, int(t&31)) + r.s[2*i+1]
+		a, b, c, d = b, c, d, a
+	}
+
+	binary.LittleEndian.PutUint32(dst[0:], a+r.s[2*rc6Rounds+2])
+	binary.LittleEndian.PutUint32(dst[4:], b)
+	binary.LittleEndian.PutUint32(dst[8:], c+r.s[2*rc6Rounds+3])
+	binary.LittleEndian.PutUint32(dst[12:], d)
+}
+
+func (r *rc6Block) Decrypt(dst, src []byte) {
+	if len(src) < rc6BlockSize || len(dst) < rc6BlockSize {
+		panic("crypto/rc6: input not full block")
+	}
+	a := binary.LittleEndian.Uint32(src[0:]) - r.s[2*rc6Rounds+2]
+	b := binary.LittleEndian.Uint32(src[4:])
+	c := binary.LittleEndian.Uint32(src[8:]) - r.s[2*rc6Rounds+3]
+	d := binary.LittleEndian.Uint32(src[12:])
+
+	for i := rc6Rounds; i >= 1; i-- {
+		a, b, c, d = d, a, b, c
+		u := bits.RotateLeft32(d*(2*d+1), 5)
+		t := bits.RotateLeft32(b*(2*b+1), 5)
+		c = bits.RotateLeft32(c-r.s[2*i+1], -int(t&31)) ^ u
+		a = bits.RotateLeft32(a-r.s[2*i], -int(u&31)) ^ t
+	}
+
+	binary.LittleEndian.PutUint32(dst[0:], a)
+	binary.LittleEndian.PutUint32(dst[4:], b-r.s[0])
+	binary.LittleEndian.PutUint32(dst[8:], c)
+	binary.LittleEndian.PutUint32(dst[12:], d-r.s[1])
+}
@@ -0,0 +1,76 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRC6Encrypt(t *testing.T) {
+	cipher := NewRC6Cipher()
+
+	data := []byte(`There is a theory which states that if ever anyone discovers
+exactly what the Universe is for and why it is here, it will
+instantly disappear and be replaced by something even more
+bizarre and inexplicable. There is another theory which states
+that this has already happened.`)
+	key := bytes.Repeat([]byte{0x42}, keySize)
+
+	modeCipher, err := cipher.NewAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, modeCipher.NonceSize())
+	encryptedData := modeCipher.Seal(nil, nonce, data, nil)
+	assert.NotEmpty(t, encryptedData, "no encrypted data")
+	assert.False(t, bytes.Compare(data, encryptedData[:len(data)]) == 0, "encrypted data should be different")
+
+	decryptedData, err := modeCipher.Open(nil, nonce, encryptedData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data, decryptedData, "decrypted data does not match original data")
+}
+
+// known answer tests from the RC6 submission
+func TestRC6KnownAnswers(t *testing.T) {
+	vectors := []struct{ key, plain, cipher string }{
+		{"00000000000000000000000000000000",
+			"00000000000000000000000000000000", "8fc3a53656b1f778c129df4e9848a41e"},
+		{"0123456789abcdef0112233445566778",
+			"02132435465768798a9bacbdcedfe0f1", "524e192f4715c6231f51f6367ea43f18"},
+		{"000000000000000000000000000000000000000000000000",
+			"00000000000000000000000000000000", "6cd61bcb190b30384e8a3f168690ae82"},
+		{"0123456789abcdef0112233445566778899aabbccddeeff0",
+			"02132435465768798a9bacbdcedfe0f1", "688329d019e505041e52e92af95291d4"},
+		{"0000000000000000000000000000000000000000000000000000000000000000",
+			"00000000000000000000000000000000", "8f5fbd0510d15fa893fa3fda6e857ec2"},
+		{"0123456789abcdef0112233445566778899aabbccddeeff01032547698badcfe",
+			"02132435465768798a9bacbdcedfe0f1", "c8241816f0d7e48920ad16a1674e5d48"},
+	}
+	for _, v := range vectors {
+		key, _ := hex.DecodeString(v.key)
+		plain, _ := hex.DecodeString(v.plain)
+		block, err := newRC6Block(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encrypted := make([]byte, rc6BlockSize)
+		block.Encrypt(encrypted, plain)
+		assert.Equal(t, v.cipher, hex.EncodeToString(encrypted), "key %s: ciphertext mismatch", v.key)
+
+		decrypted := make([]byte, rc6BlockSize)
+		block.Decrypt(decrypted, encrypted)
+		assert.Equal(t, plain, decrypted, "key %s: plaintext mismatch", v.key)
+	}
+}
+
+func TestRC6KeySize(t *testing.T) {
+	for _, size := range []int{0, 8, 20, 33} {
+		_, err := newRC6Block(make([]byte, size))
+		assert.Error(t, err, "key size %d should not be accepted", size)
+	}
+}
@@ -0,0 +1,135 @@
+package crypto
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// registry holds the ciphers that can be used, keyed by CipherType and by
+// name and alias. Names are matched without regard to letter case or
+// surrounding whitespace.
+var registry = struct {
+	sync.RWMutex
+	byType map[CipherType]Cipher
+	byName map[string]CipherType
+	order  []CipherType
+}{
+	byType: map[CipherType]Cipher{},
+	byName: map[string]CipherType{},
+}
+
+// aliasedCipher is implemented by ciphers that can be selected by other names
+// than GetName
+type aliasedCipher interface {
+	GetAliases() []string
+}
+
+func init() {
+	mustRegister(NewAES256Cipher(), "AES", "AES-256", "AES256-GCM")
+	mustRegister(NewTwofishCipher())
+	mustRegister(NewSerpentCipher())
+	mustRegister(NewXChaCha20Cipher(), "XCHACHA20-POLY1305")
+	mustRegister(NewSecretboxCipher(), "NACL", "XSALSA20-POLY1305")
+	mustRegister(NewBlowfishCipher())
+	mustRegister(NewTripleDESCipher(), "3DES", "TRIPLEDES")
+	mustRegister(NewRC6Cipher())
+	mustRegister(NewMARSCipher())
+	mustRegister(NewAESTwofishCipher())
+	mustRegister(NewAESTwofishSerpentCipher())
+	mustRegister(NewSerpentAESCipher())
+	mustRegister(NewSerpentTwofishAESCipher())
+	mustRegister(NewTwofishSerpentCipher())
+}
+
+// Register adds a cipher so data can be sealed and opened with it. The cipher
+// is found by its GetType, which is stored in the header of sealed data, and
+// by GetName. A cipher with a GetAliases() []string method can also be found
+// by those names. Types, names and aliases must not be registered already,
+// and the type must be FirstCustomCipher or above. Ciphers from other
+// packages are usually registered from init().
+func Register(c Cipher) error {
+	if c != nil && c.GetType() < FirstCustomCipher {
+		return errors.Errorf("cipher type %d is reserved for krypt, use %d or above", c.GetType(), FirstCustomCipher)
+	}
+	var aliases []string
+	if aliased, ok := c.(aliasedCipher); ok {
+		aliases = aliased.GetAliases()
+	}
+	return register(c, aliases...)
+}
+
+func mustRegister(c Cipher, aliases ...string) {
+	if err := register(c, aliases...); err != nil {
+		panic(err)
+	}
+}
+
+func register(c Cipher, aliases ...string) error {
+	if c == nil {
+		return errors.New("cannot register a nil cipher")
+	}
+	cipherType := c.GetType()
+	if cipherType == Unknown {
+		return errors.Errorf("cannot register cipher %s with the unknown type", c.GetName())
+	}
+	names := append([]string{c.GetName()}, aliases...)
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.byType[cipherType]; ok {
+		return errors.Errorf("cipher type %d is already registered", cipherType)
+	}
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = cipherKey(name)
+		if len(keys[i]) == 0 {
+			return errors.Errorf("cannot register cipher type %d with an empty name", cipherType)
+		}
+		if _, ok := registry.byName[keys[i]]; ok {
+			return errors.Errorf("cipher name %s is already registered", name)
+		}
+	}
+
+	registry.byType[cipherType] = c
+	for _, key := range keys {
+		registry.byName[key] = cipherType
+	}
+	registry.order = append(registry.order, cipherType)
+	return nil
+}
+
+// cipherKey returns the registry key of a cipher name or alias
+func cipherKey(name string) string {
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+// lookupCipher returns the cipher registered for the type
+func lookupCipher(cipherType CipherType) (Cipher, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	c, ok := registry.byType[cipherType]
+	return c, ok
+}
+
+// lookupCipherType returns the type registered for the name or alias
+func lookupCipherType(name string) (CipherType, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	cipherType, ok := registry.byName[cipherKey(name)]
+	return cipherType, ok
+}
+
+// registeredCiphers returns the registered ciphers in the order they were
+// registered
+func registeredCiphers() []Cipher {
+	registry.RLock()
+	defer registry.RUnlock()
+	ciphers := make([]Cipher, len(registry.order))
+	for i, cipherType := range registry.order {
+		ciphers[i] = registry.byType[cipherType]
+	}
+	return ciphers
+}
@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCipher is AES256-GCM registered under another type and name, like a
+// cipher from another package would be
+type testCipher struct {
+	*AES256Cipher
+	cipherType CipherType
+	name       string
+}
+
+func (c *testCipher) GetName() string        { return c.name }
+func (c *testCipher) GetType() CipherType    { return c.cipherType }
+func (c *testCipher) GetDescription() string { return "test cipher" }
+func (c *testCipher) GetAliases() []string   { return []string{c.name + "-alias"} }
+
+func TestRegister(t *testing.T) {
+	c := &testCipher{AES256Cipher: NewAES256Cipher(), cipherType: CipherType(200), name: "Test-Registered"}
+	if err := Register(c); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"TEST-REGISTERED", "test-registered", " Test-Registered-Alias "} {
+		cipherType, err := GetCipherTypeByName(name)
+		assert.Nil(t, err, "%s: unexpected error", name)
+		assert.Equal(t, CipherType(200), cipherType, "%s: cipher type mismatch", name)
+	}
+	assert.Contains(t, GetCipherList(), Cipher(c), "registered cipher should be listed")
+
+	data := []byte("sealed with a registered cipher")
+	encryptedData, err := EncryptWithOptions(CipherType(200), []byte("geronimo"), data, Options{KDF: NewPBKDF2KDF(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherType, err := ReadCipherType(bytes.NewReader(encryptedData))
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, CipherType(200), cipherType, "header cipher type mismatch")
+	decryptedData, err := Decrypt([]byte("geronimo"), encryptedData)
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, data, decryptedData, "decrypted data does not match")
+}
+
+func TestRegisterConflicts(t *testing.T) {
+	err := Register(&testCipher{AES256Cipher: NewAES256Cipher(), cipherType: AES256, name: "OTHER-AES"})
+	assert.EqualError(t, err, "cipher type 1 is reserved for krypt, use 128 or above", "built-in type should not be registered")
+
+	err = Register(&testCipher{AES256Cipher: NewAES256Cipher(), cipherType: CipherType(100), name: "OTHER-AES"})
+	assert.Error(t, err, "reserved type should not be registered")
+
+	err = Register(&testCipher{AES256Cipher: NewAES256Cipher(), cipherType: CipherType(203), name: "Test-Twice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Register(&testCipher{AES256Cipher: NewAES256Cipher(), cipherType: CipherType(203), name: "OTHER-AES"})
+	assert.EqualError(t, err, "cipher type 203 is already registered", "type should not be registered twice")
+
+	err = Register(&testCipher{AES256Cipher: NewAES256Cipher(), cipherType: CipherType(201), name: "aes256"})
+	assert.EqualError(t, err, "cipher name aes256 is already registered", "name should not be registered twice")
+
+	err = Register(&testCipher{AES256Cipher: NewAES256Cipher(), cipherType: Unknown, name: "UNKNOWN-AES"})
+	assert.Error(t, err, "unknown type should not be registered")
+
+	err = Register(&testCipher{AES256Cipher: NewAES256Cipher(), cipherType: CipherType(202), name: " "})
+	assert.Error(t, err, "empty name should not be registered")
+
+	_, err = GetCipherTypeByName("OTHER-AES")
+	assert.Error(t, err, "failed registration should not add names")
+}
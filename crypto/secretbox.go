@@ -0,0 +1,109 @@
+package crypto
+
+import (
+	"crypto/cipher"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const secretboxName = "SECRETBOX"
+
+const secretboxNonceSize = 24
+
+// SecretboxCipher encrypts using NaCl secretbox (XSalsa20-Poly1305)
+type SecretboxCipher struct {
+	description string
+	name        string
+	cipherType  CipherType
+}
+
+// NewSecretboxCipher constructor
+func NewSecretboxCipher() *SecretboxCipher {
+	r := &SecretboxCipher{}
+	r.description = "NaCl secretbox (XSalsa20-Poly1305) cipher"
+	r.name = secretboxName
+	r.cipherType = SECRETBOX
+
+	return r
+}
+
+// GetDescription returns description string
+func (c *SecretboxCipher) GetDescription() string {
+	return c.description
+}
+
+// GetName returns name string
+func (c *SecretboxCipher) GetName() string {
+	return c.name
+}
+
+// GetType returns CryptType
+func (c *SecretboxCipher) GetType() CipherType {
+	return c.cipherType
+}
+
+// GetInfo returns the block size, mode and speed of the cipher
+func (c *SecretboxCipher) GetInfo() CipherInfo {
+	return CipherInfo{KeySize: keySize, Mode: "Poly1305", AEAD: true, Speed: SpeedFast}
+}
+
+// NewAEAD returns secretbox keyed with key, wrapped as an AEAD cipher
+func (c *SecretboxCipher) NewAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("creating aead cipher: invalid key size")
+	}
+	r := &secretboxAEAD{}
+	copy(r.key[:], key)
+	return r, nil
+}
+
+// NoAssociatedData reports that the chunks are sealed as plain secretboxes
+// of the payload key, without the header as associated data
+func (c *SecretboxCipher) NoAssociatedData() bool {
+	return true
+}
+
+// secretboxAEAD adapts secretbox to the cipher.AEAD interface. Secretbox has
+// no associated data, so the output is a plain secretbox of the key and none
+// may be given.
+type secretboxAEAD struct {
+	key [32]byte
+}
+
+func (a *secretboxAEAD) NonceSize() int {
+	return secretboxNonceSize
+}
+
+func (a *secretboxAEAD) Overhead() int {
+	return secretbox.Overhead
+}
+
+func (a *secretboxAEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != secretboxNonceSize {
+		panic("crypto: incorrect nonce length given to secretbox")
+	}
+	if len(additionalData) > 0 {
+		panic("crypto: associated data given to secretbox")
+	}
+	var boxNonce [secretboxNonceSize]byte
+	copy(boxNonce[:], nonce)
+	return secretbox.Seal(dst, plaintext, &boxNonce, &a.key)
+}
+
+func (a *secretboxAEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != secretboxNonceSize {
+		panic("crypto: incorrect nonce length given to secretbox")
+	}
+	if len(additionalData) > 0 {
+		return nil, errors.New("secretbox takes no associated data")
+	}
+	var boxNonce [secretboxNonceSize]byte
+	copy(boxNonce[:], nonce)
+	plaintext, ok := secretbox.Open(dst, ciphertext, &boxNonce, &a.key)
+	if !ok {
+		return nil, errors.New("message authentication failed")
+	}
+	return plaintext, nil
+}
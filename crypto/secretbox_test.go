@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+func TestSecretboxEncrypt(t *testing.T) {
+	cipher := NewSecretboxCipher()
+
+	data := []byte(`There is a theory which states that if ever anyone discovers
+exactly what the Universe is for and why it is here, it will
+instantly disappear and be replaced by something even more
+bizarre and inexplicable. There is another theory which states
+that this has already happened.`)
+	key := bytes.Repeat([]byte{0x42}, keySize)
+
+	modeCipher, err := cipher.NewAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, modeCipher.NonceSize())
+	encryptedData := modeCipher.Seal(nil, nonce, data, nil)
+	assert.NotEmpty(t, encryptedData, "no encrypted data")
+	assert.False(t, bytes.Compare(data, encryptedData[:len(data)]) == 0, "encrypted data should be different")
+
+	decryptedData, err := modeCipher.Open(nil, nonce, encryptedData, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data, decryptedData, "decrypted data does not match original data")
+}
+
+func TestSecretboxInterop(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	pass := []byte("geronimo")
+
+	sealed, err := EncryptWithOptions(SECRETBOX, pass, data, Options{KDF: NewPBKDF2KDF(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the chunks of krypt data are plain secretboxes of the payload key
+	reader := bytes.NewReader(sealed)
+	headerData := new(bytes.Buffer)
+	h, err := readHeader(io.TeeReader(reader, headerData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := openEnvelope(reader, h, headerData.Bytes(), pass, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := envelope.payloadKey(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var boxKey [32]byte
+	var boxNonce [24]byte
+	copy(boxKey[:], key)
+	chunkNonce(boxNonce[:], h.noncePrefix, 0, true)
+	opened, ok := secretbox.Open(nil, chunk, &boxNonce, &boxKey)
+	assert.True(t, ok, "chunk should open as a secretbox")
+	assert.Equal(t, data, opened, "opened data does not match original data")
+
+	// and secretboxes open as krypt chunks
+	modeCipher, err := NewSecretboxCipher().NewAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	box := secretbox.Seal(nil, data, &boxNonce, &boxKey)
+	opened, err = modeCipher.Open(nil, boxNonce[:], box, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data, opened, "opened data does not match original data")
+}
+
+func TestSecretboxAdditionalData(t *testing.T) {
+	modeCipher, err := NewSecretboxCipher().NewAEAD(bytes.Repeat([]byte{0x42}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := bytes.Repeat([]byte{0x24}, secretboxNonceSize)
+	data := []byte("This is the test data to compare")
+
+	sealed := modeCipher.Seal(nil, nonce, data, nil)
+	_, err = modeCipher.Open(nil, nonce, sealed, []byte("header"))
+	assert.Error(t, err, "associated data should not be taken")
+	assert.Panics(t, func() { modeCipher.Seal(nil, nonce, data, []byte("header")) }, "associated data should not be taken")
+
+	_, err = EncryptWithAD(SECRETBOX, []byte("geronimo"), data, []byte("context"))
+	assert.Error(t, err, "additional data should not be taken")
+}
+
+func TestSecretboxKrypt(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	pass := []byte("geronimo")
+
+	encryptedData, err := Encrypt(SECRETBOX, pass, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decryptedData, err := Decrypt(pass, encryptedData)
+	if err != nil {
+		t.Fatal("error decrypting: ", err)
+	}
+	assert.Equal(t, data, decryptedData, "decrypted data does not match original data")
+}
@@ -0,0 +1,44 @@
+package shamir
+
+// arithmetic in GF(256) with the AES polynomial x^8+x^4+x^3+x+1, multiplication
+// and division go through log and exp tables of the generator 3
+var (
+	expTable [510]byte
+	logTable [256]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		expTable[i+255] = x
+		logTable[x] = byte(i)
+		// multiply by the generator, x*3 = x*2 ^ x
+		double := x << 1
+		if x&0x80 != 0 {
+			double ^= 0x1b
+		}
+		x ^= double
+	}
+}
+
+// add adds, and subtracts, in GF(256)
+func add(a byte, b byte) byte {
+	return a ^ b
+}
+
+// mul multiplies in GF(256)
+func mul(a byte, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+// div divides in GF(256), b must not be 0
+func div(a byte, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
@@ -0,0 +1,126 @@
+// Package shamir splits a secret into parts with Shamir's secret sharing over
+// GF(256), so that any threshold of the parts recover the secret and fewer
+// reveal nothing about it.
+package shamir
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// MaxParts is the most parts a secret can be split into, each part is the
+// value of the polynomials at a distinct non zero x in GF(256)
+const MaxParts = 255
+
+// Split splits the secret into parts, any threshold of which recover it with
+// Combine. Each part is as long as the secret plus one byte, its x coordinate.
+func Split(secret []byte, parts int, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("cannot split an empty secret")
+	}
+	if threshold < 2 {
+		return nil, errors.New("threshold must be at least 2")
+	}
+	if parts < threshold {
+		return nil, errors.New("parts cannot be less than the threshold")
+	}
+	if parts > MaxParts {
+		return nil, errors.Errorf("parts cannot be more than %d", MaxParts)
+	}
+
+	out := make([][]byte, parts)
+	for i := range out {
+		out[i] = make([]byte, len(secret)+1)
+		out[i][len(secret)] = uint8(i + 1)
+	}
+
+	coefficients := make([]byte, threshold)
+	for idx, value := range secret {
+		if err := randomPolynomial(coefficients, value); err != nil {
+			return nil, err
+		}
+		for i := range out {
+			out[i][idx] = evaluate(coefficients, out[i][len(secret)])
+		}
+	}
+	return out, nil
+}
+
+// Combine recovers the secret from parts made by Split. Fewer parts than the
+// threshold, or parts of different secrets, give a wrong secret and no error.
+func Combine(parts [][]byte) ([]byte, error) {
+	if len(parts) < 2 {
+		return nil, errors.New("at least 2 parts are needed")
+	}
+	size := len(parts[0])
+	if size < 2 {
+		return nil, errors.New("parts are too short")
+	}
+
+	xs := make([]byte, len(parts))
+	seen := map[byte]bool{}
+	for i, part := range parts {
+		if len(part) != size {
+			return nil, errors.New("parts must all be the same length")
+		}
+		x := part[size-1]
+		if x == 0 || seen[x] {
+			return nil, errors.New("parts must have distinct non zero x coordinates")
+		}
+		seen[x] = true
+		xs[i] = x
+	}
+
+	secret := make([]byte, size-1)
+	ys := make([]byte, len(parts))
+	for idx := range secret {
+		for i, part := range parts {
+			ys[i] = part[idx]
+		}
+		secret[idx] = interpolate(xs, ys)
+	}
+	return secret, nil
+}
+
+// randomPolynomial fills coefficients with a random polynomial of degree
+// len(coefficients)-1 whose value at 0 is intercept
+func randomPolynomial(coefficients []byte, intercept byte) error {
+	if _, err := io.ReadFull(rand.Reader, coefficients[1:]); err != nil {
+		return errors.Wrap(err, "randomizing polynomial")
+	}
+	coefficients[0] = intercept
+	// a zero leading coefficient would lower the degree, and the threshold
+	for coefficients[len(coefficients)-1] == 0 {
+		if _, err := io.ReadFull(rand.Reader, coefficients[len(coefficients)-1:]); err != nil {
+			return errors.Wrap(err, "randomizing polynomial")
+		}
+	}
+	return nil
+}
+
+// evaluate returns the value of the polynomial at x
+func evaluate(coefficients []byte, x byte) byte {
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = add(mul(y, x), coefficients[i])
+	}
+	return y
+}
+
+// interpolate returns the value at 0 of the polynomial through the points
+func interpolate(xs []byte, ys []byte) byte {
+	var y byte
+	for i := range xs {
+		basis := byte(1)
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			basis = mul(basis, div(xs[j], add(xs[i], xs[j])))
+		}
+		y = add(y, mul(ys[i], basis))
+	}
+	return y
+}
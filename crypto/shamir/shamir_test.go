@@ -0,0 +1,71 @@
+package shamir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGF256(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			product := mul(byte(a), byte(b))
+			assert.NotZero(t, product, "%d*%d should not be zero", a, b)
+			if div(product, byte(b)) != byte(a) {
+				t.Fatalf("%d*%d/%d should be %d", a, b, b, a)
+			}
+		}
+	}
+	assert.Equal(t, byte(0xc1), mul(0x57, 0x83), "product mismatch")
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("split between the people who need it")
+	parts, err := Split(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, parts, 5, "part count mismatch")
+
+	// every combination of 3 or more parts recovers the secret
+	for a := 0; a < 5; a++ {
+		for b := a + 1; b < 5; b++ {
+			for c := b + 1; c < 5; c++ {
+				combined, err := Combine([][]byte{parts[c], parts[a], parts[b]})
+				assert.Nil(t, err, "unexpected error")
+				assert.Equal(t, secret, combined, "secret mismatch for parts %d, %d, %d", a, b, c)
+			}
+		}
+	}
+	combined, err := Combine(parts)
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, secret, combined, "secret mismatch for all parts")
+
+	combined, err = Combine(parts[:2])
+	assert.Nil(t, err, "unexpected error")
+	assert.NotEqual(t, secret, combined, "fewer parts than the threshold should not recover the secret")
+}
+
+func TestSplitInvalid(t *testing.T) {
+	_, err := Split(nil, 5, 3)
+	assert.Error(t, err, "empty secret should not split")
+	_, err = Split([]byte("secret"), 5, 1)
+	assert.Error(t, err, "threshold of 1 should not split")
+	_, err = Split([]byte("secret"), 2, 3)
+	assert.Error(t, err, "fewer parts than the threshold should not split")
+	_, err = Split([]byte("secret"), 256, 3)
+	assert.Error(t, err, "too many parts should not split")
+}
+
+func TestCombineInvalid(t *testing.T) {
+	parts, err := Split([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Combine(parts[:1])
+	assert.Error(t, err, "a single part should not combine")
+	_, err = Combine([][]byte{parts[0], parts[0]})
+	assert.Error(t, err, "duplicate parts should not combine")
+	_, err = Combine([][]byte{parts[0], parts[1][1:]})
+	assert.Error(t, err, "parts of different lengths should not combine")
+}
@@ -0,0 +1,135 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"github.com/gesquive/krypt/crypto/shamir"
+	"github.com/pkg/errors"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// text form of a share, the threshold and the shamir part encoded like an
+// X25519 key
+const sharePrefix = "KRYPT-SHARE-1"
+
+// SplitRecipient wraps data keys under a random secret that is split into
+// shares, any threshold of which open the data again
+type SplitRecipient struct {
+	secret    []byte
+	threshold int
+	shares    int
+}
+
+// SplitKey creates a recipient for a new random secret, and splits the
+// secret into shares so that any threshold of them open what it wraps
+func SplitKey(shares int, threshold int) (*SplitRecipient, []string, error) {
+	secret := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return nil, nil, errors.Wrapf(err, "randomizing secret")
+	}
+	parts, err := shamir.Split(secret, shares, threshold)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "splitting secret")
+	}
+
+	encoded := make([]string, len(parts))
+	for i, part := range parts {
+		encoded[i] = encodeKey(sharePrefix, append([]byte{byte(threshold)}, part...))
+	}
+	return &SplitRecipient{secret: secret, threshold: threshold, shares: shares}, encoded, nil
+}
+
+// Wrap seals the data key under a key derived from the secret
+func (r *SplitRecipient) Wrap(dataKey []byte) (*Stanza, error) {
+	wrapKey, err := splitWrapKey(r.secret)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := wrapDataKey(wrapKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Stanza{Type: SplitStanza, Fields: [][]byte{{byte(r.threshold), byte(r.shares)}, wrapped}}, nil
+}
+
+// SplitIdentity unwraps data keys sealed under a secret recovered from its
+// shares
+type SplitIdentity struct {
+	secret []byte
+}
+
+// CombineShares recovers the secret from at least the threshold of shares
+// made by SplitKey
+func CombineShares(shares []string) (*SplitIdentity, error) {
+	parts := make([][]byte, len(shares))
+	threshold := 0
+	for i, share := range shares {
+		part, err := ParseShare(share)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && int(part[0]) != threshold {
+			return nil, errors.New("shares were not split together")
+		}
+		threshold = int(part[0])
+		parts[i] = part[1:]
+	}
+	if len(shares) < threshold {
+		return nil, errors.Errorf("%d shares are needed, got %d", threshold, len(shares))
+	}
+
+	secret, err := shamir.Combine(parts)
+	if err != nil {
+		return nil, errors.Wrap(err, "combining shares")
+	}
+	return &SplitIdentity{secret: secret}, nil
+}
+
+// ParseShare parses the text form of a share, its first byte is the
+// threshold and the rest the shamir part
+func ParseShare(s string) ([]byte, error) {
+	part, err := decodeKey(sharePrefix, s)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing share")
+	}
+	if len(part) != keySize+2 || part[0] < 2 {
+		return nil, errors.New("malformed share")
+	}
+	return part, nil
+}
+
+// Unwrap opens the data key from a stanza sealed under the secret
+func (i *SplitIdentity) Unwrap(stanza *Stanza) ([]byte, error) {
+	if stanza.Type != SplitStanza {
+		return nil, nil
+	}
+	if _, _, err := SplitStanzaShares(stanza); err != nil {
+		return nil, err
+	}
+	wrapKey, err := splitWrapKey(i.secret)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapDataKey(wrapKey, stanza.Fields[1])
+}
+
+// SplitStanzaShares returns how many of how many shares open a split stanza
+func SplitStanzaShares(stanza *Stanza) (int, int, error) {
+	if stanza.Type != SplitStanza || len(stanza.Fields) != 2 || len(stanza.Fields[0]) != 2 {
+		return 0, 0, errors.New("malformed split stanza")
+	}
+	return int(stanza.Fields[0][0]), int(stanza.Fields[0][1]), nil
+}
+
+// splitWrapKey derives the key wrapping key from the secret, which is random
+// so it needs no password key derivation
+func splitWrapKey(secret []byte) ([]byte, error) {
+	wrapKey := make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, []byte("krypt split")), wrapKey); err != nil {
+		return nil, errors.Wrapf(err, "deriving wrap key")
+	}
+	return wrapKey, nil
+}
@@ -0,0 +1,66 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCrypt(t *testing.T) {
+	recipient, shares, err := SplitKey(5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, shares, 5, "share count mismatch")
+
+	plainText := []byte("three of five must agree")
+	opts := Options{KDF: NewPBKDF2KDF(1000), Recipients: []Recipient{recipient}}
+	sealed, err := EncryptWithOptions(AES256, []byte("geronimo"), plainText, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, picked := range [][]string{shares[:3], shares[2:], {shares[4], shares[0], shares[2]}, shares} {
+		identity, err := CombineShares(picked)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opened, err := DecryptWithOptions(nil, sealed, Options{Identities: []Identity{identity}})
+		assert.Nil(t, err, "unexpected error")
+		assert.Equal(t, plainText, opened, "plain text mismatch")
+	}
+
+	_, err = CombineShares(shares[:2])
+	assert.EqualError(t, err, "3 shares are needed, got 2", "fewer shares than the threshold should not combine")
+
+	_, otherShares, err := SplitKey(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = CombineShares([]string{shares[0], shares[1], otherShares[2]})
+	assert.Error(t, err, "shares of different splits should not combine")
+
+	stanzas, err := ReadStanzas(bytes.NewReader(sealed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	threshold, total, err := SplitStanzaShares(stanzas[0])
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, []int{3, 5}, []int{threshold, total}, "split stanza shares mismatch")
+}
+
+func TestParseShare(t *testing.T) {
+	_, shares, err := SplitKey(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := ParseShare(shares[0])
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, byte(2), part[0], "threshold mismatch")
+
+	_, err = ParseShare(shares[0][:len(shares[0])-1])
+	assert.Error(t, err, "truncated share should not parse")
+	_, err = ParseShare("KRYPT-SECRET-KEY-1AAAA")
+	assert.Error(t, err, "other keys should not parse as shares")
+}
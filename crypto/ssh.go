@@ -0,0 +1,284 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSH stanzas start with a tag, the first 4 bytes of the SHA-256 of the SSH
+// public key, so identities can skip stanzas wrapped for other keys
+const (
+	sshTagSize       = 4
+	sshRSALabel      = "krypt ssh-rsa"
+	sshRSAMinKeySize = 2048
+)
+
+// curve25519P is the field prime 2^255 - 19
+var curve25519P, _ = new(big.Int).SetString("7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffed", 16)
+
+// SSHEd25519Recipient wraps data keys to an ssh-ed25519 public key. The key
+// is converted to its X25519 form and wrapped like an X25519Recipient.
+type SSHEd25519Recipient struct {
+	sshKey    ssh.PublicKey
+	publicKey []byte
+}
+
+// SSHRSARecipient wraps data keys to an ssh-rsa public key with RSA-OAEP
+type SSHRSARecipient struct {
+	sshKey    ssh.PublicKey
+	publicKey *rsa.PublicKey
+}
+
+// NewSSHRecipient creates a recipient from an ssh-ed25519 or ssh-rsa public key
+func NewSSHRecipient(sshKey ssh.PublicKey) (Recipient, error) {
+	cryptoKey, ok := sshKey.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, errors.Errorf("unsupported ssh key type %s", sshKey.Type())
+	}
+
+	switch publicKey := cryptoKey.CryptoPublicKey().(type) {
+	case ed25519.PublicKey:
+		montgomeryKey, err := ed25519PublicKeyToCurve25519(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return &SSHEd25519Recipient{sshKey: sshKey, publicKey: montgomeryKey}, nil
+	case *rsa.PublicKey:
+		if publicKey.Size()*8 < sshRSAMinKeySize {
+			return nil, errors.Errorf("ssh-rsa keys must be at least %d bits", sshRSAMinKeySize)
+		}
+		return &SSHRSARecipient{sshKey: sshKey, publicKey: publicKey}, nil
+	default:
+		return nil, errors.Errorf("unsupported ssh key type %s", sshKey.Type())
+	}
+}
+
+// ParseSSHRecipient parses an SSH public key in the authorized_keys format
+func ParseSSHRecipient(s string) (Recipient, error) {
+	sshKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(s))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing ssh public key")
+	}
+	return NewSSHRecipient(sshKey)
+}
+
+// ParseSSHRecipients reads SSH public keys in the authorized_keys format, one
+// per line. Empty lines and lines starting with '#' are skipped.
+func ParseSSHRecipients(reader io.Reader) ([]Recipient, error) {
+	recipients := []Recipient{}
+	err := parseKeyLines(reader, func(line string) error {
+		recipient, err := ParseSSHRecipient(line)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, recipient)
+		return nil
+	})
+	return recipients, err
+}
+
+// String returns the public key in the authorized_keys format
+func (r *SSHEd25519Recipient) String() string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(r.sshKey)))
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the SSH public key
+func (r *SSHEd25519Recipient) Fingerprint() string {
+	return ssh.FingerprintSHA256(r.sshKey)
+}
+
+// Wrap seals the data key to the X25519 form of the public key
+func (r *SSHEd25519Recipient) Wrap(dataKey []byte) (*Stanza, error) {
+	x25519Recipient, err := NewX25519Recipient(r.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	stanza, err := x25519Recipient.Wrap(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	stanza.Type = SSHEd25519Stanza
+	stanza.Fields = append([][]byte{sshTag(r.sshKey)}, stanza.Fields...)
+	return stanza, nil
+}
+
+// String returns the public key in the authorized_keys format
+func (r *SSHRSARecipient) String() string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(r.sshKey)))
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the SSH public key
+func (r *SSHRSARecipient) Fingerprint() string {
+	return ssh.FingerprintSHA256(r.sshKey)
+}
+
+// Wrap seals the data key to the public key with RSA-OAEP and SHA-256
+func (r *SSHRSARecipient) Wrap(dataKey []byte) (*Stanza, error) {
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, r.publicKey, dataKey, []byte(sshRSALabel))
+	if err != nil {
+		return nil, errors.Wrap(err, "wrapping data key")
+	}
+	return &Stanza{Type: SSHRSAStanza, Fields: [][]byte{sshTag(r.sshKey), wrapped}}, nil
+}
+
+// SSHEd25519Identity unwraps data keys sealed to an ssh-ed25519 key
+type SSHEd25519Identity struct {
+	sshKey   ssh.PublicKey
+	identity *X25519Identity
+}
+
+// SSHRSAIdentity unwraps data keys sealed to an ssh-rsa key
+type SSHRSAIdentity struct {
+	sshKey     ssh.PublicKey
+	privateKey *rsa.PrivateKey
+}
+
+// NewSSHIdentity creates an identity from an ed25519 or rsa private key
+func NewSSHIdentity(privateKey interface{}) (Identity, error) {
+	switch key := privateKey.(type) {
+	case *ed25519.PrivateKey:
+		return NewSSHIdentity(*key)
+	case ed25519.PrivateKey:
+		sshKey, err := ssh.NewPublicKey(key.Public())
+		if err != nil {
+			return nil, err
+		}
+		identity, err := NewX25519Identity(ed25519PrivateKeyToCurve25519(key))
+		if err != nil {
+			return nil, err
+		}
+		return &SSHEd25519Identity{sshKey: sshKey, identity: identity}, nil
+	case *rsa.PrivateKey:
+		sshKey, err := ssh.NewPublicKey(&key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		return &SSHRSAIdentity{sshKey: sshKey, privateKey: key}, nil
+	default:
+		return nil, errors.New("unsupported ssh private key type")
+	}
+}
+
+// ParseSSHIdentity parses an unencrypted SSH private key file. Encrypted keys
+// return an *ssh.PassphraseMissingError.
+func ParseSSHIdentity(pemBytes []byte) (Identity, error) {
+	privateKey, err := ssh.ParseRawPrivateKey(pemBytes)
+	if err != nil {
+		return nil, err
+	}
+	return NewSSHIdentity(privateKey)
+}
+
+// ParseSSHIdentityWithPassphrase parses an encrypted SSH private key file
+func ParseSSHIdentityWithPassphrase(pemBytes []byte, passphrase []byte) (Identity, error) {
+	privateKey, err := ssh.ParseRawPrivateKeyWithPassphrase(pemBytes, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return NewSSHIdentity(privateKey)
+}
+
+// Recipient returns the recipient matching the identity
+func (i *SSHEd25519Identity) Recipient() Recipient {
+	r, _ := NewSSHRecipient(i.sshKey)
+	return r
+}
+
+// Unwrap opens the data key from a stanza sealed to the public key
+func (i *SSHEd25519Identity) Unwrap(stanza *Stanza) ([]byte, error) {
+	if stanza.Type != SSHEd25519Stanza {
+		return nil, nil
+	}
+	if len(stanza.Fields) != 3 {
+		return nil, errors.New("malformed ssh-ed25519 stanza")
+	}
+	if !bytes.Equal(stanza.Fields[0], sshTag(i.sshKey)) {
+		return nil, nil
+	}
+	return i.identity.Unwrap(&Stanza{Type: X25519Stanza, Fields: stanza.Fields[1:]})
+}
+
+// Recipient returns the recipient matching the identity
+func (i *SSHRSAIdentity) Recipient() Recipient {
+	r, _ := NewSSHRecipient(i.sshKey)
+	return r
+}
+
+// Unwrap opens the data key from a stanza sealed to the public key
+func (i *SSHRSAIdentity) Unwrap(stanza *Stanza) ([]byte, error) {
+	if stanza.Type != SSHRSAStanza {
+		return nil, nil
+	}
+	if len(stanza.Fields) != 2 {
+		return nil, errors.New("malformed ssh-rsa stanza")
+	}
+	if !bytes.Equal(stanza.Fields[0], sshTag(i.sshKey)) {
+		return nil, nil
+	}
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), nil, i.privateKey, stanza.Fields[1], []byte(sshRSALabel))
+	if err != nil {
+		return nil, errors.Wrap(err, "unwrapping data key")
+	}
+	return dataKey, nil
+}
+
+func sshTag(sshKey ssh.PublicKey) []byte {
+	sum := sha256.Sum256(sshKey.Marshal())
+	return sum[:sshTagSize]
+}
+
+// ed25519PublicKeyToCurve25519 converts an Edwards point to the birationally
+// equivalent Montgomery u-coordinate, u = (1 + y) / (1 - y)
+func ed25519PublicKeyToCurve25519(publicKey ed25519.PublicKey) ([]byte, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key size")
+	}
+
+	// the key holds y little endian, with the sign of x in the top bit
+	le := append([]byte{}, publicKey...)
+	le[31] &= 0x7f
+	y := new(big.Int).SetBytes(reverseBytes(le))
+	if y.Cmp(curve25519P) >= 0 {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+
+	denominator := new(big.Int).Sub(big.NewInt(1), y)
+	denominator.Mod(denominator, curve25519P)
+	if denominator.Sign() == 0 {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+	u := new(big.Int).Add(big.NewInt(1), y)
+	u.Mul(u, denominator.ModInverse(denominator, curve25519P))
+	u.Mod(u, curve25519P)
+
+	out := make([]byte, curve25519.PointSize)
+	uBytes := u.Bytes()
+	copy(out[len(out)-len(uBytes):], uBytes)
+	return reverseBytes(out), nil
+}
+
+// ed25519PrivateKeyToCurve25519 returns the X25519 scalar of an ed25519 key,
+// the first half of the SHA-512 of the seed. X25519 clamps it when used.
+func ed25519PrivateKeyToCurve25519(privateKey ed25519.PrivateKey) []byte {
+	sum := sha512.Sum512(privateKey.Seed())
+	return sum[:curve25519.ScalarSize]
+}
+
+func reverseBytes(b []byte) []byte {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b
+}
@@ -0,0 +1,136 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// an ssh-agent can only sign, so the wrap key is derived from the signature
+// of a random challenge. ssh-ed25519 and rsa-sha2-256 signatures are
+// deterministic, so the agent makes the same signature again to unwrap.
+const (
+	sshAgentChallengeSize = 32
+	sshAgentSignPrefix    = "krypt ssh-agent challenge\x00"
+)
+
+// SSHAgentKey wraps and unwraps data keys with a key held in an ssh-agent.
+// It is both the Recipient and the Identity of the key, only someone with the
+// key loaded in their agent can seal to it or open it.
+type SSHAgentKey struct {
+	agent  agent.Agent
+	sshKey ssh.PublicKey
+}
+
+// NewSSHAgentKey uses the ssh-ed25519 or ssh-rsa key held in the agent
+func NewSSHAgentKey(sshAgent agent.Agent, sshKey ssh.PublicKey) (*SSHAgentKey, error) {
+	switch sshKey.Type() {
+	case ssh.KeyAlgoED25519:
+	case ssh.KeyAlgoRSA:
+		if _, ok := sshAgent.(agent.ExtendedAgent); !ok {
+			return nil, errors.New("ssh-agent cannot make rsa-sha2-256 signatures")
+		}
+	default:
+		return nil, errors.Errorf("unsupported ssh key type %s", sshKey.Type())
+	}
+	return &SSHAgentKey{agent: sshAgent, sshKey: sshKey}, nil
+}
+
+// SSHAgentKeys returns the keys held in the agent that can wrap data keys,
+// other keys are skipped
+func SSHAgentKeys(sshAgent agent.Agent) ([]*SSHAgentKey, error) {
+	agentKeys, err := sshAgent.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "listing ssh-agent keys")
+	}
+
+	keys := []*SSHAgentKey{}
+	for _, agentKey := range agentKeys {
+		sshKey, err := ssh.ParsePublicKey(agentKey.Marshal())
+		if err != nil {
+			continue
+		}
+		if key, err := NewSSHAgentKey(sshAgent, sshKey); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// String returns the public key in the authorized_keys format
+func (k *SSHAgentKey) String() string {
+	return string(bytes.TrimSpace(ssh.MarshalAuthorizedKey(k.sshKey)))
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the SSH public key
+func (k *SSHAgentKey) Fingerprint() string {
+	return ssh.FingerprintSHA256(k.sshKey)
+}
+
+// Wrap seals the data key under the signature of a new random challenge
+func (k *SSHAgentKey) Wrap(dataKey []byte) (*Stanza, error) {
+	challenge := make([]byte, sshAgentChallengeSize)
+	if _, err := io.ReadFull(rand.Reader, challenge); err != nil {
+		return nil, errors.Wrapf(err, "randomizing challenge")
+	}
+	wrapKey, err := k.wrapKey(challenge)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := wrapDataKey(wrapKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Stanza{Type: SSHAgentStanza, Fields: [][]byte{sshTag(k.sshKey), challenge, wrapped}}, nil
+}
+
+// Unwrap has the agent sign the challenge of the stanza again to open the
+// data key
+func (k *SSHAgentKey) Unwrap(stanza *Stanza) ([]byte, error) {
+	if stanza.Type != SSHAgentStanza {
+		return nil, nil
+	}
+	if len(stanza.Fields) != 3 || len(stanza.Fields[1]) != sshAgentChallengeSize {
+		return nil, errors.New("malformed ssh-agent stanza")
+	}
+	if !bytes.Equal(stanza.Fields[0], sshTag(k.sshKey)) {
+		return nil, nil
+	}
+	wrapKey, err := k.wrapKey(stanza.Fields[1])
+	if err != nil {
+		return nil, err
+	}
+	return unwrapDataKey(wrapKey, stanza.Fields[2])
+}
+
+// wrapKey derives the key wrapping key from the signature of the challenge
+func (k *SSHAgentKey) wrapKey(challenge []byte) ([]byte, error) {
+	data := append([]byte(sshAgentSignPrefix), challenge...)
+
+	var signature *ssh.Signature
+	var err error
+	if k.sshKey.Type() == ssh.KeyAlgoRSA {
+		signature, err = k.agent.(agent.ExtendedAgent).SignWithFlags(k.sshKey, data, agent.SignatureFlagRsaSha256)
+	} else {
+		signature, err = k.agent.Sign(k.sshKey, data)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "signing with ssh-agent")
+	}
+	if err := k.sshKey.Verify(data, signature); err != nil {
+		return nil, errors.Wrap(err, "verifying ssh-agent signature")
+	}
+
+	wrapKey := make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, signature.Blob, challenge, []byte("krypt ssh-agent")), wrapKey); err != nil {
+		return nil, errors.Wrapf(err, "deriving wrap key")
+	}
+	return wrapKey, nil
+}
@@ -0,0 +1,142 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// startTestAgent serves an in memory agent holding the keys on a local unix
+// socket, and returns a client connected to it like one using SSH_AUTH_SOCK
+// and a func to stop the agent
+func startTestAgent(t *testing.T, keys ...interface{}) (agent.ExtendedAgent, func()) {
+	keyring := agent.NewKeyring()
+	for _, key := range keys {
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "krypt-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("unix", filepath.Join(dir, "agent.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				agent.ServeAgent(keyring, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	conn, err := net.Dial("unix", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return agent.NewClient(conn), func() {
+		conn.Close()
+		listener.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSSHAgentCrypt(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshAgent, stop := startTestAgent(t, ed25519Key, rsaKey)
+	defer stop()
+
+	keys, err := SSHAgentKeys(sshAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, keys, 2, "agent key count mismatch")
+
+	plainText := []byte("opened with the ssh-agent")
+	for _, key := range keys {
+		sealed, err := EncryptWithOptions(AES256, nil, plainText, Options{Recipients: []Recipient{key}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		opened, err := DecryptWithOptions(nil, sealed, Options{Identities: []Identity{key}})
+		assert.Nil(t, err, "%s: unexpected error", key.sshKey.Type())
+		assert.Equal(t, plainText, opened, "%s: plain text mismatch", key.sshKey.Type())
+
+		// the key must still be loaded to open the data
+		other := keys[0]
+		if other == key {
+			other = keys[1]
+		}
+		_, err = DecryptWithOptions(nil, sealed, Options{Identities: []Identity{other}})
+		assert.IsType(t, &NoIdentityMatchError{}, err, "other agent key should not open the data")
+	}
+
+	sealed, err := EncryptWithOptions(AES256, nil, plainText, Options{Recipients: []Recipient{keys[0]}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sshAgent.RemoveAll(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = DecryptWithOptions(nil, sealed, Options{Identities: []Identity{keys[0]}})
+	assert.Error(t, err, "data should not open once the key is removed from the agent")
+}
+
+func TestSSHAgentStanza(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshAgent, stop := startTestAgent(t, ed25519Key)
+	defer stop()
+
+	keys, err := SSHAgentKeys(sshAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+
+	stanza, err := keys[0].Wrap(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, SSHAgentStanza, stanza.Type, "stanza type mismatch")
+
+	// an ssh identity for the same key cannot open an agent stanza
+	sshIdentity, err := NewSSHIdentity(ed25519Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := sshIdentity.Unwrap(stanza)
+	assert.Nil(t, err, "unexpected error")
+	assert.Nil(t, unwrapped, "ssh identity should not unwrap an agent stanza")
+
+	stanza.Fields[1] = stanza.Fields[1][1:]
+	_, err = keys[0].Unwrap(stanza)
+	assert.Error(t, err, "malformed stanza should not unwrap")
+}
@@ -0,0 +1,414 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// defaultChunkSize is the amount of plain text sealed in each chunk
+const defaultChunkSize = 64 * 1024
+
+// the last 5 bytes of every chunk nonce hold the chunk counter and final flag
+const nonceCounterSize = 5
+
+const keySize = 32
+
+// Chunked streams seal the plain text in fixed-size chunks, each with its own
+// nonce. A chunk nonce takes the form prefix|counter|final where prefix is
+// random per stream, counter is the big-endian chunk index and final is 1 for
+// the last chunk and 0 otherwise. This means reordered chunks fail to open,
+// and a stream cut short at a chunk boundary is caught by the missing final
+// chunk. Every chunk is sealed with the header, followed by any additional data
+// given by the caller, as associated data so the header cannot be altered
+// either, except for ciphers that take no associated data, see
+// chunkAdditionalData. Output takes the form header|stanzas|mac|chunk|chunk... where only
+// the header is bound to the chunks and the mac guards the stanzas, the key
+// slots of the password and recipients.
+
+// NewEncryptWriter returns a writer that seals everything written to it with
+// the given cipher and password and writes the result to w. Close must be
+// called to seal the final chunk; it does not close w.
+func NewEncryptWriter(w io.Writer, cipherType CipherType, password []byte) (io.WriteCloser, error) {
+	return NewEncryptWriterWithOptions(w, cipherType, password, Options{})
+}
+
+// NewEncryptWriterWithAD works like NewEncryptWriter, and also binds
+// additionalData to the sealed data. The additional data is not stored, the
+// same additional data must be given to open the data again.
+func NewEncryptWriterWithAD(w io.Writer, cipherType CipherType, password []byte, additionalData []byte) (io.WriteCloser, error) {
+	return NewEncryptWriterWithOptions(w, cipherType, password, Options{AdditionalData: additionalData})
+}
+
+// NewEncryptWriterWithOptions works like NewEncryptWriter, using the given options
+func NewEncryptWriterWithOptions(w io.Writer, cipherType CipherType, password []byte, opts Options) (io.WriteCloser, error) {
+	c, err := getCipher(cipherType)
+	if err != nil {
+		return nil, err
+	}
+	if IsLegacyCipher(cipherType) && !opts.AllowLegacy {
+		return nil, NewLegacyCipherError()
+	}
+
+	// the password is a key slot next to the recipients
+	if len(password) > 0 {
+		opts.Recipients = append(append([]Recipient{}, opts.Recipients...), NewPasswordRecipientWithKeyfile(password, opts.Keyfile, opts.KDF))
+	}
+	envelope, err := sealEnvelope(opts)
+	if err != nil {
+		return nil, err
+	}
+	if envelope == nil {
+		return nil, errors.New("no password or recipients to seal with")
+	}
+
+	h := &header{
+		version:    libVersion,
+		cipherType: cipherType,
+		flags:      flagEnvelope,
+		chunkSize:  defaultChunkSize,
+	}
+
+	// we need the salt as random as possible
+	h.salt = make([]byte, defaultSaltSize)
+	if _, err := io.ReadFull(rand.Reader, h.salt); err != nil {
+		return nil, errors.Wrapf(err, "randomizing salt")
+	}
+
+	key, err := envelope.payloadKey(h)
+	if err != nil {
+		return nil, err
+	}
+	modeCipher, err := c.NewAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	h.noncePrefix = make([]byte, modeCipher.NonceSize()-nonceCounterSize)
+	if _, err := io.ReadFull(rand.Reader, h.noncePrefix); err != nil {
+		return nil, errors.Wrapf(err, "randomizing nonce")
+	}
+
+	headerData, err := h.marshal()
+	if err != nil {
+		return nil, err
+	}
+	additionalData, err := chunkAdditionalData(c, headerData, opts.AdditionalData)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(headerData); err != nil {
+		return nil, errors.Wrap(err, "writing krypt info")
+	}
+	stanzaData, err := envelope.marshalStanzas(h, headerData)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(stanzaData); err != nil {
+		return nil, errors.Wrap(err, "writing krypt stanzas")
+	}
+
+	writer := newChunkWriter(w, modeCipher, h.noncePrefix, int(h.chunkSize))
+	writer.additionalData = additionalData
+	return writer, nil
+}
+
+// NewDecryptReader returns a reader that opens the sealed data read from r
+// with the given password. The cipher and its parameters are derived from the
+// stream metadata. Version 1 data, which was sealed in one piece, is read
+// fully before the first Read returns.
+func NewDecryptReader(r io.Reader, password []byte) (io.Reader, error) {
+	return NewDecryptReaderWithAD(r, password, nil)
+}
+
+// NewDecryptReaderWithAD works like NewDecryptReader, for data that was sealed
+// with additionalData bound to it.
+func NewDecryptReaderWithAD(r io.Reader, password []byte, additionalData []byte) (io.Reader, error) {
+	return NewDecryptReaderWithOptions(r, password, Options{AdditionalData: additionalData})
+}
+
+// NewDecryptReaderWithOptions works like NewDecryptReader, using the given
+// options. Data sealed to recipients is opened with the identities instead of
+// the password.
+func NewDecryptReaderWithOptions(r io.Reader, password []byte, opts Options) (io.Reader, error) {
+	reader, _, err := NewDecryptReaderWithEnvelope(r, password, opts)
+	return reader, err
+}
+
+// NewDecryptReaderWithEnvelope works like NewDecryptReaderWithOptions, and
+// also returns the envelope of data sealed to recipients, so that it can be
+// sealed again for the same recipients. The envelope is nil for version 1
+// data, which was sealed with a password.
+func NewDecryptReaderWithEnvelope(r io.Reader, password []byte, opts Options) (io.Reader, *Envelope, error) {
+	headerData := new(bytes.Buffer)
+	h, err := readHeader(io.TeeReader(r, headerData))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "reading krypt")
+	}
+
+	c, err := getCipher(h.cipherType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if h.version == legacyVersion {
+		if password, err = promptPassword(password, opts); err != nil {
+			return nil, nil, err
+		}
+		if len(opts.AdditionalData) > 0 {
+			return nil, nil, errors.New("version 1 data cannot hold additional data")
+		}
+		payload, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "reading payload")
+		}
+		plainText, err := decryptLegacy(c, password, payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bytes.NewReader(plainText), nil, nil
+	}
+
+	envelope, err := openEnvelope(r, h, headerData.Bytes(), password, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := envelope.payloadKey(h)
+	if err != nil {
+		return nil, nil, err
+	}
+	modeCipher, err := c.NewAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(h.noncePrefix) != modeCipher.NonceSize()-nonceCounterSize {
+		return nil, nil, errors.New("invalid krypt nonce size")
+	}
+
+	reader := newChunkReader(r, modeCipher, h.noncePrefix, int(h.chunkSize))
+	if reader.additionalData, err = chunkAdditionalData(c, headerData.Bytes(), opts.AdditionalData); err != nil {
+		return nil, nil, err
+	}
+	return reader, envelope, nil
+}
+
+// chunkAdditionalData returns the associated data the chunks are sealed with,
+// the header followed by the additional data of the caller. Ciphers that take
+// no associated data seal the chunks without it, the header is authenticated
+// by the header mac of the envelope instead and there is no room for the
+// additional data of the caller.
+func chunkAdditionalData(c Cipher, headerData []byte, additionalData []byte) ([]byte, error) {
+	if takesAssociatedData(c) {
+		return append(headerData, additionalData...), nil
+	}
+	if len(additionalData) > 0 {
+		return nil, errors.Errorf("%s cannot bind additional data", c.GetName())
+	}
+	return nil, nil
+}
+
+// promptPassword returns the password, asking for it with the prompt of the
+// options when none was given
+func promptPassword(password []byte, opts Options) ([]byte, error) {
+	if len(password) > 0 || opts.PasswordPrompt == nil {
+		return password, nil
+	}
+	password, err := opts.PasswordPrompt()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading password")
+	}
+	return password, nil
+}
+
+// chunkNonce fills nonce with the nonce for the given chunk
+func chunkNonce(nonce []byte, prefix []byte, counter uint32, final bool) {
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[len(prefix):], counter)
+	nonce[len(nonce)-1] = 0
+	if final {
+		nonce[len(nonce)-1] = 1
+	}
+}
+
+// chunkWriter buffers plain text and seals it a chunk at a time
+type chunkWriter struct {
+	writer         io.Writer
+	modeCipher     cipher.AEAD
+	noncePrefix    []byte
+	additionalData []byte
+	nonce          []byte
+	counter        uint32
+	chunkSize      int
+	buffer         []byte
+	sealed         []byte
+	closed         bool
+	err            error
+}
+
+func newChunkWriter(w io.Writer, modeCipher cipher.AEAD, noncePrefix []byte, chunkSize int) *chunkWriter {
+	return &chunkWriter{
+		writer:      w,
+		modeCipher:  modeCipher,
+		noncePrefix: noncePrefix,
+		nonce:       make([]byte, modeCipher.NonceSize()),
+		chunkSize:   chunkSize,
+		buffer:      make([]byte, 0, chunkSize),
+		sealed:      make([]byte, 0, chunkSize+modeCipher.Overhead()),
+	}
+}
+
+// Write buffers p and seals every chunk that fills up. A full chunk is only
+// sealed once more data arrives, so that the final chunk is never empty
+// unless the whole stream is.
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	if c.closed {
+		return 0, errors.New("write to closed stream")
+	}
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(c.buffer) == c.chunkSize {
+			if c.err = c.sealChunk(false); c.err != nil {
+				return written, c.err
+			}
+		}
+		n := copy(c.buffer[len(c.buffer):c.chunkSize], p)
+		c.buffer = c.buffer[:len(c.buffer)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the remaining data as the final chunk
+func (c *chunkWriter) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.err != nil {
+		return c.err
+	}
+	return c.sealChunk(true)
+}
+
+func (c *chunkWriter) sealChunk(final bool) error {
+	if c.counter == math.MaxUint32 {
+		return errors.New("stream too long")
+	}
+	chunkNonce(c.nonce, c.noncePrefix, c.counter, final)
+	c.sealed = c.modeCipher.Seal(c.sealed[:0], c.nonce, c.buffer, c.additionalData)
+	if _, err := c.writer.Write(c.sealed); err != nil {
+		return errors.Wrap(err, "writing chunk")
+	}
+	c.buffer = c.buffer[:0]
+	c.counter++
+	return nil
+}
+
+// chunkReader opens sealed chunks as they are read
+type chunkReader struct {
+	reader         *bufio.Reader
+	modeCipher     cipher.AEAD
+	noncePrefix    []byte
+	additionalData []byte
+	nonce          []byte
+	counter        uint32
+	chunkSize      int
+	sealed         []byte
+	buffer         []byte
+	plainText      []byte
+	done           bool
+	err            error
+}
+
+func newChunkReader(r io.Reader, modeCipher cipher.AEAD, noncePrefix []byte, chunkSize int) *chunkReader {
+	return &chunkReader{
+		reader:      bufio.NewReader(r),
+		modeCipher:  modeCipher,
+		noncePrefix: noncePrefix,
+		nonce:       make([]byte, modeCipher.NonceSize()),
+		chunkSize:   chunkSize,
+		sealed:      make([]byte, chunkSize+modeCipher.Overhead()),
+		buffer:      make([]byte, 0, chunkSize),
+	}
+}
+
+// Read returns opened plain text. No data from a chunk is returned until the
+// whole chunk has been authenticated.
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.plainText) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		c.err = c.openChunk()
+	}
+
+	n := copy(p, c.plainText)
+	c.plainText = c.plainText[n:]
+	return n, nil
+}
+
+func (c *chunkReader) openChunk() error {
+	n, err := io.ReadFull(c.reader, c.sealed)
+	final := false
+	switch err {
+	case nil:
+		// a full chunk is the last one only if nothing follows it
+		if _, perr := c.reader.Peek(1); perr == io.EOF {
+			final = true
+		} else if perr != nil {
+			return errors.Wrap(perr, "reading chunk")
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		final = true
+	default:
+		return errors.Wrap(err, "reading chunk")
+	}
+
+	if n < c.modeCipher.Overhead() {
+		return &TruncatedError{"stream is truncated"}
+	}
+
+	chunkNonce(c.nonce, c.noncePrefix, c.counter, final)
+	plainText, err := c.modeCipher.Open(c.buffer[:0], c.nonce, c.sealed[:n], c.additionalData)
+	if err != nil {
+		return c.openError(final, n)
+	}
+	if final && len(plainText) == 0 && c.counter > 0 {
+		// the writer never seals an empty final chunk after other chunks
+		return &TamperedError{"stream is malformed"}
+	}
+
+	c.plainText = plainText
+	c.counter++
+	c.done = final
+	return nil
+}
+
+// openError returns why the chunk read last did not open. A chunk that opens
+// as a chunk other than the final one means the stream was cut short at a
+// chunk boundary.
+func (c *chunkReader) openError(final bool, n int) error {
+	if final {
+		chunkNonce(c.nonce, c.noncePrefix, c.counter, false)
+		if _, err := c.modeCipher.Open(c.buffer[:0], c.nonce, c.sealed[:n], c.additionalData); err == nil {
+			return &TruncatedError{"stream is truncated"}
+		}
+	}
+	return errors.Wrap(NewTamperedError(), "decrypting chunk")
+}
@@ -0,0 +1,172 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamCrypt(t *testing.T) {
+	pass := []byte("geronimo")
+
+	for _, size := range []int{0, 1, defaultChunkSize - 1, defaultChunkSize,
+		defaultChunkSize + 1, 3*defaultChunkSize + 17} {
+		data := make([]byte, size)
+		if _, err := rand.Read(data); err != nil {
+			t.Fatal(err)
+		}
+
+		sealed := new(bytes.Buffer)
+		writer, err := NewEncryptWriter(sealed, AES256, pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(writer, bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		reader, err := NewDecryptReader(sealed, pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opened, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		assert.Equal(t, data, opened, "size %d: decrypted data does not match", size)
+	}
+}
+
+func TestStreamWrongPassword(t *testing.T) {
+	sealed, err := Encrypt(TWOFISH, []byte("geronimo"), []byte("This is the test data to compare"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Decrypt([]byte("cowabunga"), sealed)
+	assert.True(t, errors.Is(err, ErrWrongPassword), "wrong password should not decrypt: %v", err)
+}
+
+func TestStreamPasswordPrompt(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	sealed, err := Encrypt(AES256, []byte("geronimo"), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prompts := 0
+	opts := Options{PasswordPrompt: func() ([]byte, error) {
+		prompts++
+		return []byte("geronimo"), nil
+	}}
+	opened, err := DecryptWithOptions(nil, sealed, opts)
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, data, opened, "decrypted data does not match original data")
+	assert.Equal(t, 1, prompts, "password should be prompted for once")
+
+	// the prompt is not used when a password is given
+	opened, err = DecryptWithOptions([]byte("geronimo"), sealed, opts)
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, data, opened, "decrypted data does not match original data")
+	assert.Equal(t, 1, prompts, "password should not be prompted for")
+}
+
+func TestStreamLegacyVersion(t *testing.T) {
+	data := []byte("This is the test data to compare")
+	pass := []byte("geronimo")
+
+	payload, err := NewSerpentCipher().Encrypt(data, pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decryptedData, err := Decrypt(pass, mockKrypt(legacyVersion, SERPENT, payload))
+	if err != nil {
+		t.Fatal("error decrypting: ", err)
+	}
+	assert.Equal(t, data, decryptedData, "decrypted data does not match original data")
+}
+
+func TestChunkTruncated(t *testing.T) {
+	chunks := mockChunks(t, 4)
+
+	// drop the final chunk so the stream ends on a chunk boundary
+	_, err := openChunks(chunks[:3])
+	assert.True(t, errors.Is(err, ErrTruncated), "truncated stream should not decrypt: %v", err)
+
+	// drop part of the final chunk
+	truncated := append(bytes.Join(chunks[:3], nil), chunks[3][:5]...)
+	_, err = openChunks([][]byte{truncated})
+	assert.True(t, errors.Is(err, ErrTruncated), "truncated chunk should not decrypt: %v", err)
+}
+
+func TestChunkReordered(t *testing.T) {
+	chunks := mockChunks(t, 4)
+
+	_, err := openChunks([][]byte{chunks[1], chunks[0], chunks[2], chunks[3]})
+	assert.True(t, errors.Is(err, ErrTampered), "reordered stream should not decrypt: %v", err)
+}
+
+func TestChunkTampered(t *testing.T) {
+	chunks := mockChunks(t, 4)
+	chunks[2][0] ^= 0x01
+
+	reader := newChunkReader(bytes.NewReader(bytes.Join(chunks, nil)),
+		mockAEAD(t), mockNoncePrefix, mockChunkSize)
+	opened, err := ioutil.ReadAll(reader)
+	assert.True(t, errors.Is(err, ErrTampered), "tampered stream should not decrypt: %v", err)
+	assert.Len(t, opened, 2*mockChunkSize, "only authenticated chunks should be returned")
+}
+
+const mockChunkSize = 16
+
+var mockNoncePrefix = []byte("prefix!")
+
+func mockAEAD(t *testing.T) cipher.AEAD {
+	modeCipher, err := NewAES256Cipher().NewAEAD(bytes.Repeat([]byte{0x42}, keySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return modeCipher
+}
+
+// mockChunks seals n full chunks and returns them separately
+func mockChunks(t *testing.T, n int) [][]byte {
+	sealed := new(bytes.Buffer)
+	writer := newChunkWriter(sealed, mockAEAD(t), mockNoncePrefix, mockChunkSize)
+	if _, err := writer.Write(bytes.Repeat([]byte("x"), n*mockChunkSize)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	chunkLen := mockChunkSize + writer.modeCipher.Overhead()
+	var chunks [][]byte
+	for sealed.Len() > 0 {
+		chunks = append(chunks, append([]byte{}, sealed.Next(chunkLen)...))
+	}
+	if len(chunks) != n {
+		t.Fatalf("expected %d chunks, found %d", n, len(chunks))
+	}
+	return chunks
+}
+
+func openChunks(chunks [][]byte) ([]byte, error) {
+	modeCipher, err := NewAES256Cipher().NewAEAD(bytes.Repeat([]byte{0x42}, keySize))
+	if err != nil {
+		return nil, err
+	}
+	reader := newChunkReader(bytes.NewReader(bytes.Join(chunks, nil)),
+		modeCipher, mockNoncePrefix, mockChunkSize)
+	return ioutil.ReadAll(reader)
+}
@@ -0,0 +1,66 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+)
+
+const tdesName = "TDES"
+
+// Triple-DES takes three 64-bit DES keys, but only 56 bits of each are used
+const (
+	tripleDESKeySize          = 24
+	tripleDESEffectiveKeySize = 21
+)
+
+// TripleDESCipher encrypts using Triple-DES in CTR mode with HMAC-SHA256. It is a legacy
+// cipher, kept to open data from old systems, see DecryptOpenSSL; its 64-bit
+// block makes it unfit for large amounts of data.
+type TripleDESCipher struct {
+	description string
+	name        string
+	cipherType  CipherType
+}
+
+// NewTripleDESCipher constructor
+func NewTripleDESCipher() *TripleDESCipher {
+	r := &TripleDESCipher{}
+	r.description = "Triple-DES-CTR-HMAC-SHA256 cipher (legacy)"
+	r.name = tdesName
+	r.cipherType = TDES
+
+	return r
+}
+
+// GetDescription returns description string
+func (c *TripleDESCipher) GetDescription() string {
+	return c.description
+}
+
+// GetName returns name string
+func (c *TripleDESCipher) GetName() string {
+	return c.name
+}
+
+// GetType returns CryptType
+func (c *TripleDESCipher) GetType() CipherType {
+	return c.cipherType
+}
+
+// GetInfo returns the block size, mode and speed of the cipher
+func (c *TripleDESCipher) GetInfo() CipherInfo {
+	return CipherInfo{KeySize: tripleDESKeySize, EffectiveKeySize: tripleDESEffectiveKeySize,
+		BlockSize: des.BlockSize, Mode: "CTR+HMAC-SHA256", Speed: SpeedSlow}
+}
+
+// IsLegacy reports that this cipher should only be used to open old data
+func (c *TripleDESCipher) IsLegacy() bool {
+	return true
+}
+
+// NewAEAD returns Triple-DES in encrypt-then-MAC form keyed with key
+func (c *TripleDESCipher) NewAEAD(key []byte) (cipher.AEAD, error) {
+	return newEtmAEAD(c.name, key, tripleDESKeySize, func(encKey []byte) (cipher.Block, error) {
+		return des.NewTripleDESCipher(encKey)
+	})
+}
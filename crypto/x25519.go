@@ -0,0 +1,223 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// text forms of X25519 keys take the form prefix|base32(key|checksum), where
+// the checksum is the first 4 bytes of SHA-256(prefix|key). Public keys are
+// lower case and private keys are upper case.
+const (
+	x25519PublicPrefix = "kryptpub1"
+	x25519SecretPrefix = "KRYPT-SECRET-KEY-1"
+	x25519KeySize      = curve25519.ScalarSize
+	keyChecksumSize    = 4
+)
+
+var keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// X25519Recipient wraps data keys to an X25519 public key. Each wrap uses a
+// fresh ephemeral key, the stanza holds the ephemeral public key and the data
+// key sealed with ChaCha20-Poly1305 under a key derived from the shared secret.
+type X25519Recipient struct {
+	publicKey []byte
+}
+
+// NewX25519Recipient creates a recipient from a raw public key
+func NewX25519Recipient(publicKey []byte) (*X25519Recipient, error) {
+	if len(publicKey) != x25519KeySize {
+		return nil, errors.New("invalid x25519 public key size")
+	}
+	return &X25519Recipient{publicKey: append([]byte{}, publicKey...)}, nil
+}
+
+// ParseX25519Recipient parses a public key in its text form
+func ParseX25519Recipient(s string) (*X25519Recipient, error) {
+	publicKey, err := decodeKey(x25519PublicPrefix, s)
+	if err != nil {
+		return nil, err
+	}
+	return NewX25519Recipient(publicKey)
+}
+
+// String returns the public key in its text form
+func (r *X25519Recipient) String() string {
+	return strings.ToLower(encodeKey(x25519PublicPrefix, r.publicKey))
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the public key
+func (r *X25519Recipient) Fingerprint() string {
+	sum := sha256.Sum256(r.publicKey)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// Wrap seals the data key to the public key
+func (r *X25519Recipient) Wrap(dataKey []byte) (*Stanza, error) {
+	ephemeral := make([]byte, x25519KeySize)
+	if _, err := io.ReadFull(rand.Reader, ephemeral); err != nil {
+		return nil, errors.Wrapf(err, "randomizing ephemeral key")
+	}
+	ephemeralShare, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := curve25519.X25519(ephemeral, r.publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "computing shared secret")
+	}
+
+	wrapKey, err := x25519WrapKey(sharedSecret, ephemeralShare, r.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := wrapDataKey(wrapKey, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Stanza{Type: X25519Stanza, Fields: [][]byte{ephemeralShare, wrapped}}, nil
+}
+
+// X25519Identity unwraps data keys sealed to its public key
+type X25519Identity struct {
+	secretKey []byte
+	publicKey []byte
+}
+
+// GenerateX25519Identity creates a new random identity
+func GenerateX25519Identity() (*X25519Identity, error) {
+	secretKey := make([]byte, x25519KeySize)
+	if _, err := io.ReadFull(rand.Reader, secretKey); err != nil {
+		return nil, errors.Wrapf(err, "randomizing secret key")
+	}
+	return NewX25519Identity(secretKey)
+}
+
+// NewX25519Identity creates an identity from a raw secret key
+func NewX25519Identity(secretKey []byte) (*X25519Identity, error) {
+	if len(secretKey) != x25519KeySize {
+		return nil, errors.New("invalid x25519 secret key size")
+	}
+	publicKey, err := curve25519.X25519(secretKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	return &X25519Identity{secretKey: append([]byte{}, secretKey...), publicKey: publicKey}, nil
+}
+
+// ParseX25519Identity parses a private key in its text form
+func ParseX25519Identity(s string) (*X25519Identity, error) {
+	secretKey, err := decodeKey(x25519SecretPrefix, s)
+	if err != nil {
+		return nil, err
+	}
+	return NewX25519Identity(secretKey)
+}
+
+// String returns the private key in its text form
+func (i *X25519Identity) String() string {
+	return encodeKey(x25519SecretPrefix, i.secretKey)
+}
+
+// Recipient returns the recipient matching the identity
+func (i *X25519Identity) Recipient() *X25519Recipient {
+	r, _ := NewX25519Recipient(i.publicKey)
+	return r
+}
+
+// Unwrap opens the data key from a stanza sealed to the public key
+func (i *X25519Identity) Unwrap(stanza *Stanza) ([]byte, error) {
+	if stanza.Type != X25519Stanza {
+		return nil, nil
+	}
+	if len(stanza.Fields) != 2 || len(stanza.Fields[0]) != x25519KeySize {
+		return nil, errors.New("malformed x25519 stanza")
+	}
+
+	ephemeralShare := stanza.Fields[0]
+	sharedSecret, err := curve25519.X25519(i.secretKey, ephemeralShare)
+	if err != nil {
+		return nil, errors.Wrap(err, "computing shared secret")
+	}
+	wrapKey, err := x25519WrapKey(sharedSecret, ephemeralShare, i.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapDataKey(wrapKey, stanza.Fields[1])
+}
+
+// x25519WrapKey derives the key wrapping key from the shared secret, bound to
+// both public keys
+func x25519WrapKey(sharedSecret []byte, ephemeralShare []byte, publicKey []byte) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeralShare...), publicKey...)
+	wrapKey := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, sharedSecret, salt, []byte("krypt x25519")), wrapKey); err != nil {
+		return nil, errors.Wrapf(err, "deriving wrap key")
+	}
+	return wrapKey, nil
+}
+
+// wrapDataKey seals the data key with ChaCha20-Poly1305. Every wrap key is
+// only ever used once, so the nonce is all zeros.
+func wrapDataKey(wrapKey []byte, dataKey []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating wrap cipher")
+	}
+	return aead.Seal(nil, make([]byte, aead.NonceSize()), dataKey, nil), nil
+}
+
+// unwrapDataKey opens a data key sealed by wrapDataKey, a key that does not
+// open is reported as a nil key so other identities can be tried
+func unwrapDataKey(wrapKey []byte, wrapped []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating wrap cipher")
+	}
+	if len(wrapped) != keySize+aead.Overhead() {
+		return nil, errors.New("malformed wrapped key")
+	}
+	dataKey, err := aead.Open(nil, make([]byte, aead.NonceSize()), wrapped, nil)
+	if err != nil {
+		return nil, nil
+	}
+	return dataKey, nil
+}
+
+// encodeKey returns the text form of a key
+func encodeKey(prefix string, key []byte) string {
+	return prefix + keyEncoding.EncodeToString(append(append([]byte{}, key...), keyChecksum(prefix, key)...))
+}
+
+// decodeKey parses the text form of a key, in either letter case
+func decodeKey(prefix string, s string) ([]byte, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if !strings.HasPrefix(s, strings.ToUpper(prefix)) {
+		return nil, errors.New("invalid key prefix")
+	}
+	data, err := keyEncoding.DecodeString(s[len(prefix):])
+	if err != nil || len(data) < keyChecksumSize {
+		return nil, errors.New("invalid key encoding")
+	}
+	key := data[:len(data)-keyChecksumSize]
+	if !bytes.Equal(data[len(key):], keyChecksum(prefix, key)) {
+		return nil, errors.New("invalid key checksum")
+	}
+	return key, nil
+}
+
+func keyChecksum(prefix string, key []byte) []byte {
+	sum := sha256.Sum256(append([]byte(prefix), key...))
+	return sum[:keyChecksumSize]
+}
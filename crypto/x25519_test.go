@@ -0,0 +1,72 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestX25519KeyText(t *testing.T) {
+	identity, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient := identity.Recipient()
+	assert.True(t, strings.HasPrefix(recipient.String(), "kryptpub1"), "unexpected public key prefix")
+	assert.True(t, strings.HasPrefix(identity.String(), "KRYPT-SECRET-KEY-1"), "unexpected secret key prefix")
+
+	parsedRecipient, err := ParseX25519Recipient(recipient.String())
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, recipient, parsedRecipient, "public key mismatch")
+
+	parsedIdentity, err := ParseX25519Identity(strings.ToLower(identity.String()))
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, identity, parsedIdentity, "secret key mismatch")
+
+	// a typo is caught by the checksum
+	text := []byte(recipient.String())
+	if text[20] == 'a' {
+		text[20] = 'b'
+	} else {
+		text[20] = 'a'
+	}
+	_, err = ParseX25519Recipient(string(text))
+	assert.Error(t, err, "altered public key should not parse")
+
+	_, err = ParseX25519Recipient(identity.String())
+	assert.Error(t, err, "secret key should not parse as a public key")
+
+	assert.True(t, strings.HasPrefix(recipient.Fingerprint(), "SHA256:"), "unexpected fingerprint prefix")
+	assert.Equal(t, recipient.Fingerprint(), parsedRecipient.Fingerprint(), "fingerprint mismatch")
+}
+
+func TestX25519Wrap(t *testing.T) {
+	identity, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+
+	stanza, err := identity.Recipient().Wrap(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, X25519Stanza, stanza.Type, "stanza type mismatch")
+
+	unwrapped, err := identity.Unwrap(stanza)
+	assert.Nil(t, err, "unexpected error")
+	assert.Equal(t, dataKey, unwrapped, "data key mismatch")
+
+	unwrapped, err = other.Unwrap(stanza)
+	assert.Nil(t, err, "unexpected error")
+	assert.Nil(t, unwrapped, "other identity should not unwrap the data key")
+
+	stanza.Fields = stanza.Fields[:1]
+	_, err = identity.Unwrap(stanza)
+	assert.Error(t, err, "malformed stanza should not unwrap")
+}
@@ -0,0 +1,59 @@
+package crypto
+
+import (
+	"crypto/cipher"
+
+	"github.com/pkg/errors"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const xchacha20Name = "XCHACHA20"
+
+// XChaCha20Cipher encrypts using XChaCha20-Poly1305
+type XChaCha20Cipher struct {
+	description string
+	name        string
+	cipherType  CipherType
+}
+
+// NewXChaCha20Cipher constructor
+func NewXChaCha20Cipher() *XChaCha20Cipher {
+	r := &XChaCha20Cipher{}
+	r.description = "XChaCha20-Poly1305 cipher"
+	r.name = xchacha20Name
+	r.cipherType = XCHACHA20
+
+	return r
+}
+
+// GetDescription returns description string
+func (c *XChaCha20Cipher) GetDescription() string {
+	return c.description
+}
+
+// GetName returns name string
+func (c *XChaCha20Cipher) GetName() string {
+	return c.name
+}
+
+// GetType returns CryptType
+func (c *XChaCha20Cipher) GetType() CipherType {
+	return c.cipherType
+}
+
+// GetInfo returns the block size, mode and speed of the cipher
+func (c *XChaCha20Cipher) GetInfo() CipherInfo {
+	return CipherInfo{KeySize: chacha20poly1305.KeySize, Mode: "Poly1305", AEAD: true, Speed: SpeedFast}
+}
+
+// NewAEAD returns the XChaCha20-Poly1305 cipher keyed with key. It does not
+// need AES hardware support to be fast, and its 192-bit nonces are safe to
+// pick at random.
+func (c *XChaCha20Cipher) NewAEAD(key []byte) (cipher.AEAD, error) {
+	modeCipher, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating aead cipher")
+	}
+	return modeCipher, nil
+}